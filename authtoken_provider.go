@@ -0,0 +1,71 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	tunnel_client "golang.ngrok.com/ngrok/internal/tunnel/client"
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+// AuthtokenProvider is the callback type for [WithAuthtokenProvider]
+type AuthtokenProvider func(ctx context.Context) (string, error)
+
+// WithAuthtokenProvider configures a function which returns the authtoken to
+// authenticate with, called each time the session connects or reconnects.
+// Use it with a secrets manager that issues short-lived authtokens. It takes
+// precedence over [WithAuthtoken].
+//
+// If the ngrok service rejects the authtoken as invalid, the provider is
+// called again with a context for which [AuthtokenRefreshForced] is true, and
+// authentication is retried once with the new authtoken. Providers that cache
+// authtokens should bypass their cache for that call. If the new authtoken is
+// rejected too, the attempt fails with an error matching [ErrAuthFailed] and
+// is retried with backoff like any other failure to authenticate, since the
+// provider may have a valid authtoken by then.
+func WithAuthtokenProvider(provider AuthtokenProvider) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.AuthtokenProvider = provider
+	}
+}
+
+type forcedRefreshKey struct{}
+
+// AuthtokenRefreshForced reports whether an [AuthtokenProvider] is being
+// called because the ngrok service rejected the authtoken it last returned.
+func AuthtokenRefreshForced(ctx context.Context) bool {
+	forced, _ := ctx.Value(forcedRefreshKey{}).(bool)
+	return forced
+}
+
+// Authenticates sess with extra, getting the authtoken from provider if it's
+// set. An authtoken the ngrok service rejects is refreshed and retried once.
+func authenticate(ctx context.Context, sess tunnel_client.Session, extra proto.AuthExtra, provider AuthtokenProvider) (proto.AuthResp, error) {
+	if provider != nil {
+		token, err := provider(ctx)
+		if err != nil {
+			return proto.AuthResp{}, errAuthFailed{false, fmt.Errorf("resolving authtoken: %w", err), true}
+		}
+		extra.Authtoken = proto.ObfuscatedString(token)
+	}
+
+	resp, err := sess.Auth(extra)
+	if err != nil && authtokenRejected(resp.Error) && provider != nil {
+		token, refreshErr := provider(context.WithValue(ctx, forcedRefreshKey{}, true))
+		if refreshErr != nil {
+			return resp, errAuthFailed{false, fmt.Errorf("refreshing rejected authtoken: %w", refreshErr), true}
+		}
+		extra.Authtoken = proto.ObfuscatedString(token)
+		resp, err = sess.Auth(extra)
+	}
+	if err != nil {
+		remote := false
+		if resp.Error != "" {
+			err = errors.New(resp.Error)
+			remote = true
+		}
+		return resp, errAuthFailed{remote, err, provider != nil}
+	}
+	return resp, nil
+}
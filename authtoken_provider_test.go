@@ -0,0 +1,165 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok/config"
+	tunnel_client "golang.ngrok.com/ngrok/internal/tunnel/client"
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+const invalidAuthtoken = "The authtoken you specified is properly formed, but it is invalid.\n\nERR_NGROK_107\n"
+
+// A tunnel_client.Session which only accepts one authtoken.
+type authtokenSession struct {
+	tunnel_client.Session
+	valid    string
+	attempts []string
+	// if set, returned instead of rejecting the authtoken
+	failure string
+}
+
+func (s *authtokenSession) Auth(extra proto.AuthExtra) (proto.AuthResp, error) {
+	token := extra.Authtoken.PlainText()
+	s.attempts = append(s.attempts, token)
+	if s.failure != "" {
+		return proto.AuthResp{Error: s.failure}, errors.New(s.failure)
+	}
+	if token != s.valid {
+		return proto.AuthResp{Error: invalidAuthtoken}, errors.New(invalidAuthtoken)
+	}
+	return proto.AuthResp{ClientID: "client"}, nil
+}
+
+// An AuthtokenProvider which caches its authtoken until forced to refresh.
+type cachingProvider struct {
+	cached, fresh string
+	forced        int
+}
+
+func (p *cachingProvider) provide(ctx context.Context) (string, error) {
+	if AuthtokenRefreshForced(ctx) {
+		p.forced++
+		p.cached = p.fresh
+	}
+	return p.cached, nil
+}
+
+func TestAuthenticateProvider(t *testing.T) {
+	sess := &authtokenSession{valid: "fresh"}
+	provider := &cachingProvider{cached: "fresh", fresh: "fresh"}
+
+	resp, err := authenticate(context.Background(), sess, proto.AuthExtra{}, provider.provide)
+	require.NoError(t, err)
+	require.Equal(t, "client", resp.ClientID)
+	require.Equal(t, []string{"fresh"}, sess.attempts)
+	require.Zero(t, provider.forced)
+}
+
+func TestAuthenticateForcedRefresh(t *testing.T) {
+	sess := &authtokenSession{valid: "fresh"}
+	provider := &cachingProvider{cached: "stale", fresh: "fresh"}
+
+	_, err := authenticate(context.Background(), sess, proto.AuthExtra{}, provider.provide)
+	require.NoError(t, err)
+	require.Equal(t, []string{"stale", "fresh"}, sess.attempts)
+	require.Equal(t, 1, provider.forced)
+}
+
+func TestAuthenticateRefreshOnce(t *testing.T) {
+	sess := &authtokenSession{valid: "fresh"}
+	provider := &cachingProvider{cached: "stale", fresh: "also stale"}
+
+	_, err := authenticate(context.Background(), sess, proto.AuthExtra{}, provider.provide)
+	var authErr errAuthFailed
+	require.ErrorAs(t, err, &authErr)
+	require.True(t, authErr.Remote)
+	// the provider may have a valid authtoken by the next attempt
+	require.False(t, authErr.Permanent())
+	require.ErrorIs(t, err, ErrAuthFailed)
+	require.Equal(t, []string{"stale", "also stale"}, sess.attempts)
+	require.Equal(t, 1, provider.forced)
+}
+
+func TestAuthenticateRetryAfterRejection(t *testing.T) {
+	sess := &authtokenSession{valid: "fresh"}
+	provider := &cachingProvider{cached: "stale", fresh: "also stale"}
+
+	_, err := authenticate(context.Background(), sess, proto.AuthExtra{}, provider.provide)
+	var authErr errAuthFailed
+	require.ErrorAs(t, err, &authErr)
+	require.False(t, authErr.Permanent(), "the session must keep reconnecting")
+
+	// the authtoken is rotated before the session reconnects
+	provider.fresh = "fresh"
+	resp, err := authenticate(context.Background(), sess, proto.AuthExtra{}, provider.provide)
+	require.NoError(t, err)
+	require.Equal(t, "client", resp.ClientID)
+	require.Equal(t, []string{"stale", "also stale", "also stale", "fresh"}, sess.attempts)
+	require.Equal(t, 2, provider.forced)
+}
+
+func TestAuthenticateNoRefreshOnOtherErrors(t *testing.T) {
+	sess := &authtokenSession{valid: "fresh", failure: "Your account is limited to 1 simultaneous ngrok agent sessions.\n\nERR_NGROK_108\n"}
+	provider := &cachingProvider{cached: "fresh", fresh: "fresh"}
+
+	_, err := authenticate(context.Background(), sess, proto.AuthExtra{}, provider.provide)
+	var authErr errAuthFailed
+	require.ErrorAs(t, err, &authErr)
+	require.True(t, authErr.Remote)
+	require.False(t, authErr.Permanent())
+	require.Equal(t, []string{"fresh"}, sess.attempts)
+	require.Zero(t, provider.forced)
+}
+
+func TestAuthenticateRefreshError(t *testing.T) {
+	sess := &authtokenSession{valid: "fresh"}
+
+	_, err := authenticate(context.Background(), sess, proto.AuthExtra{}, func(ctx context.Context) (string, error) {
+		if AuthtokenRefreshForced(ctx) {
+			return "", testError
+		}
+		return "stale", nil
+	})
+	var authErr errAuthFailed
+	require.ErrorAs(t, err, &authErr)
+	require.False(t, authErr.Permanent())
+	require.ErrorIs(t, err, testError)
+	require.Equal(t, []string{"stale"}, sess.attempts)
+}
+
+func TestAuthenticateStatic(t *testing.T) {
+	sess := &authtokenSession{valid: "fresh"}
+
+	_, err := authenticate(context.Background(), sess, proto.AuthExtra{Authtoken: "stale"}, nil)
+	require.ErrorIs(t, err, errAuthFailed{})
+	// nothing to refresh
+	require.Equal(t, []string{"stale"}, sess.attempts)
+}
+
+func TestAuthenticateProviderError(t *testing.T) {
+	sess := &authtokenSession{valid: "fresh"}
+
+	_, err := authenticate(context.Background(), sess, proto.AuthExtra{}, func(ctx context.Context) (string, error) {
+		return "", testError
+	})
+	var authErr errAuthFailed
+	require.ErrorAs(t, err, &authErr)
+	require.False(t, authErr.Remote)
+	require.ErrorIs(t, err, testError)
+	require.Empty(t, sess.attempts)
+}
+
+func TestAuthtokenSource(t *testing.T) {
+	t.Setenv("TEST_NGROK_AUTHTOKEN", "from-env")
+	cfg := connectConfig{}
+	WithAuthtokenSource(config.SecretFromEnv("TEST_NGROK_AUTHTOKEN"))(&cfg)
+
+	token, err := cfg.AuthtokenProvider(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "from-env", token)
+}
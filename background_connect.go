@@ -0,0 +1,238 @@
+package ngrok
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"golang.ngrok.com/ngrok/config"
+	tunnel_client "golang.ngrok.com/ngrok/internal/tunnel/client"
+)
+
+// WithBackgroundConnect makes [Connect] return immediately, rather than
+// waiting for the session to be established. This lets an application start
+// while the ngrok service is unreachable.
+//
+// Tunnels started with [Session].Listen before the session connects are
+// queued, and bound once it does. Until then, their Accept method blocks and
+// their URL is empty. Use [Session].Ready to wait for the session explicitly.
+func WithBackgroundConnect() ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.BackgroundConnect = true
+	}
+}
+
+func (s *sessionImpl) Ready(ctx context.Context) error {
+	if s.ready == nil {
+		return nil
+	}
+	select {
+	case <-s.ready:
+		return s.readyErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reports whether the session has connected, or given up trying.
+func (s *sessionImpl) isReady() bool {
+	if s.ready == nil {
+		return true
+	}
+	select {
+	case <-s.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// Records the outcome of the first connect. Only the first call has any
+// effect.
+func (s *sessionImpl) markReady(err error) {
+	s.readyOnce.Do(func() {
+		s.readyErr = err
+		close(s.ready)
+	})
+}
+
+// A Tunnel started before its session connected. It's bound once the session
+// is ready.
+type queuedTunnel struct {
+	sess *sessionImpl
+	cfg  config.Tunnel
+
+	// cancelled when the tunnel is closed
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// closed once the tunnel has been bound, or failed to be
+	bound  chan struct{}
+	tunnel *tunnelImpl
+	err    error
+
+	status *statusWatchers
+}
+
+func newQueuedTunnel(sess *sessionImpl, cfg config.Tunnel, termination *tls.Config) *queuedTunnel {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &queuedTunnel{
+		sess:   sess,
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		bound:  make(chan struct{}),
+		status: newStatusWatchers(TunnelBinding),
+	}
+	go t.bind(termination)
+	return t
+}
+
+func (t *queuedTunnel) bind(termination *tls.Config) {
+	defer close(t.bound)
+
+	if err := t.sess.Ready(t.ctx); err != nil {
+		t.fail(err)
+		return
+	}
+	tunnel, err := t.sess.bind(t.ctx, t.cfg, termination)
+	if err != nil {
+		t.fail(err)
+		return
+	}
+
+	// closed while binding
+	if t.ctx.Err() != nil {
+		_ = tunnel.Close()
+		t.fail(t.ctx.Err())
+		return
+	}
+
+	t.sess.track(tunnel, t, t.status)
+	t.tunnel = tunnel
+}
+
+func (t *queuedTunnel) fail(err error) {
+	t.err = err
+	if t.ctx.Err() != nil {
+		t.status.set(TunnelClosed)
+	} else {
+		t.status.set(TunnelFailed)
+	}
+}
+
+// Returns the bound tunnel, or nil if it hasn't been bound yet.
+func (t *queuedTunnel) boundTunnel() *tunnelImpl {
+	select {
+	case <-t.bound:
+		return t.tunnel
+	default:
+		return nil
+	}
+}
+
+func (t *queuedTunnel) Accept() (net.Conn, error) {
+	<-t.bound
+	if t.err != nil {
+		return nil, errAcceptFailed{Inner: t.err}
+	}
+	return t.tunnel.Accept()
+}
+
+func (t *queuedTunnel) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	return t.CloseWithContext(ctx)
+}
+
+func (t *queuedTunnel) CloseWithContext(ctx context.Context) error {
+	t.cancel()
+	select {
+	case <-t.bound:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if t.tunnel == nil {
+		return nil
+	}
+	return t.tunnel.CloseWithContext(ctx)
+}
+
+// Until the tunnel is bound, the remaining methods describe it as configured.
+
+func (t *queuedTunnel) private() tunnelConfigPrivate {
+	return t.cfg.(tunnelConfigPrivate)
+}
+
+func (t *queuedTunnel) Addr() net.Addr {
+	if tun := t.boundTunnel(); tun != nil {
+		return tun.Addr()
+	}
+	return &tunnel_client.RemoteBindConfig{
+		ConfigProto: t.Proto(),
+		Labels:      t.Labels(),
+		Metadata:    t.Metadata(),
+	}
+}
+
+func (t *queuedTunnel) EndpointConfig() EndpointConfig {
+	if tun := t.boundTunnel(); tun != nil {
+		return tun.EndpointConfig()
+	}
+	return nil
+}
+
+func (t *queuedTunnel) ForwardsTo() string {
+	if tun := t.boundTunnel(); tun != nil {
+		return tun.ForwardsTo()
+	}
+	return t.private().ForwardsTo()
+}
+
+func (t *queuedTunnel) ID() string {
+	if tun := t.boundTunnel(); tun != nil {
+		return tun.ID()
+	}
+	return ""
+}
+
+func (t *queuedTunnel) Labels() map[string]string {
+	if tun := t.boundTunnel(); tun != nil {
+		return tun.Labels()
+	}
+	return t.private().Labels()
+}
+
+func (t *queuedTunnel) Metadata() string {
+	if tun := t.boundTunnel(); tun != nil {
+		return tun.Metadata()
+	}
+	return t.private().Extra().Metadata
+}
+
+func (t *queuedTunnel) Proto() string {
+	if tun := t.boundTunnel(); tun != nil {
+		return tun.Proto()
+	}
+	return t.private().Proto()
+}
+
+func (t *queuedTunnel) Status() TunnelStatus {
+	return t.status.get()
+}
+
+func (t *queuedTunnel) StatusChanges() <-chan TunnelStatus {
+	return t.status.watch()
+}
+
+func (t *queuedTunnel) Session() Session {
+	return t.sess
+}
+
+func (t *queuedTunnel) URL() string {
+	if tun := t.boundTunnel(); tun != nil {
+		return tun.URL()
+	}
+	return ""
+}
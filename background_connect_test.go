@@ -0,0 +1,153 @@
+package ngrok
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok/config"
+	tunnel_client "golang.ngrok.com/ngrok/internal/tunnel/client"
+)
+
+// A tunnel_client.Session which starts labeled tunnels that accept
+// connections sent on conns.
+type labelSession struct {
+	tunnel_client.Session
+	conns chan *tunnel_client.ProxyConn
+}
+
+func (s labelSession) ListenLabel(labels map[string]string, metadata string, forwardsTo string) (tunnel_client.Tunnel, error) {
+	return &labelTunnel{
+		conns: s.conns,
+		cfg:   &tunnel_client.RemoteBindConfig{Labels: labels, Metadata: metadata},
+	}, nil
+}
+
+type labelTunnel struct {
+	tunnel_client.Tunnel
+	conns  chan *tunnel_client.ProxyConn
+	cfg    *tunnel_client.RemoteBindConfig
+	closed bool
+}
+
+func (t *labelTunnel) Accept() (*tunnel_client.ProxyConn, error) {
+	return <-t.conns, nil
+}
+
+func (t *labelTunnel) RemoteBindConfig() *tunnel_client.RemoteBindConfig {
+	return t.cfg
+}
+
+func (t *labelTunnel) ID() string {
+	return "tunnel-id"
+}
+
+func (t *labelTunnel) Close() error {
+	t.closed = true
+	return nil
+}
+
+func (t *labelTunnel) ObserveStatus(observer tunnel_client.TunnelStatusObserver) {
+	observer(tunnel_client.TunnelOnline, "")
+}
+
+func newPendingSession() (*sessionImpl, chan *tunnel_client.ProxyConn) {
+	conns := make(chan *tunnel_client.ProxyConn)
+	sess := &sessionImpl{ready: make(chan struct{})}
+	sess.setInner(&sessionInner{Session: labelSession{conns: conns}})
+	return sess, conns
+}
+
+func TestQueuedTunnel(t *testing.T) {
+	sess, conns := newPendingSession()
+
+	tun, err := sess.Listen(context.Background(), config.LabeledTunnel(
+		config.WithLabel("edge", "edghts_123"),
+		config.WithMetadata("meta"),
+	))
+	require.NoError(t, err)
+	require.IsType(t, &queuedTunnel{}, tun)
+	require.Empty(t, tun.ID())
+	require.Equal(t, "meta", tun.Metadata())
+	require.Equal(t, map[string]string{"edge": "edghts_123"}, tun.Labels())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, sess.Ready(ctx), context.DeadlineExceeded)
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := tun.Accept()
+		require.NoError(t, err)
+		accepted <- conn
+	}()
+
+	sess.markReady(nil)
+	require.NoError(t, sess.Ready(context.Background()))
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	conns <- &tunnel_client.ProxyConn{Conn: local}
+	conn := <-accepted
+	require.Equal(t, local, conn.(*connImpl).Conn)
+	require.Equal(t, "tunnel-id", tun.ID())
+
+	// tunnels started once connected are bound right away
+	tun, err = sess.Listen(context.Background(), config.LabeledTunnel(config.WithLabel("edge", "edghts_123")))
+	require.NoError(t, err)
+	require.IsType(t, &tunnelImpl{}, tun)
+}
+
+func TestQueuedTunnelNeverConnected(t *testing.T) {
+	sess, _ := newPendingSession()
+
+	tun, err := sess.Listen(context.Background(), config.LabeledTunnel(config.WithLabel("edge", "edghts_123")))
+	require.NoError(t, err)
+
+	sess.markReady(testError)
+	require.ErrorIs(t, sess.Ready(context.Background()), testError)
+
+	_, err = tun.Accept()
+	require.ErrorIs(t, err, errAcceptFailed{})
+	require.ErrorIs(t, err, testError)
+}
+
+func TestQueuedTunnelClose(t *testing.T) {
+	sess, _ := newPendingSession()
+
+	tun, err := sess.Listen(context.Background(), config.LabeledTunnel(config.WithLabel("edge", "edghts_123")))
+	require.NoError(t, err)
+	require.NoError(t, tun.Close())
+
+	_, err = tun.Accept()
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestQueuedTunnelStatus(t *testing.T) {
+	sess, _ := newPendingSession()
+
+	tun, err := sess.Listen(context.Background(), config.LabeledTunnel(config.WithLabel("edge", "edghts_123")))
+	require.NoError(t, err)
+	require.Equal(t, TunnelBinding, tun.Status())
+	changes := tun.StatusChanges()
+
+	sess.markReady(nil)
+	require.Equal(t, TunnelOnline, <-changes)
+	require.Equal(t, TunnelOnline, tun.Status())
+}
+
+func TestQueuedTunnelFailedStatus(t *testing.T) {
+	sess, _ := newPendingSession()
+
+	tun, err := sess.Listen(context.Background(), config.LabeledTunnel(config.WithLabel("edge", "edghts_123")))
+	require.NoError(t, err)
+	changes := tun.StatusChanges()
+
+	sess.markReady(testError)
+	require.Equal(t, TunnelFailed, <-changes)
+	_, ok := <-changes
+	require.False(t, ok)
+}
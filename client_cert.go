@@ -0,0 +1,57 @@
+package ngrok
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+)
+
+// WithClientCertificate presents cert to the ngrok server when connecting, for
+// ingress that requires clients to authenticate with a certificate. Use
+// [WithClientCertificateFunc] or [WithClientCertificateFiles] for
+// certificates that are renewed while the session is running.
+func WithClientCertificate(cert tls.Certificate) ConnectOption {
+	return WithClientCertificateFunc(func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return &cert, nil
+	})
+}
+
+// WithClientCertificateFunc configures a function which returns the
+// certificate to present to the ngrok server, each time the session connects.
+// See [tls.Config].GetClientCertificate.
+func WithClientCertificateFunc(get func(*tls.CertificateRequestInfo) (*tls.Certificate, error)) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.GetClientCertificate = get
+	}
+}
+
+// WithClientCertificateFiles presents the certificate and private key from
+// the given PEM files to the ngrok server. The files are read each time the
+// session connects, so they can be renewed in place. PKCS #1, PKCS #8, and EC
+// private keys are supported.
+func WithClientCertificateFiles(certFile, keyFile string) ConnectOption {
+	return WithClientCertificateFunc(func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return loadClientCertificate(certFile, keyFile)
+	})
+}
+
+func loadClientCertificate(certFile, keyFile string) (*tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client certificate key: %w", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("loading client certificate: %w", err)
+	}
+	return &cert, nil
+}
+
+// Reports whether tlsConfig will present a client certificate.
+func presentsClientCertificate(tlsConfig *tls.Config) bool {
+	return len(tlsConfig.Certificates) > 0 || tlsConfig.GetClientCertificate != nil
+}
@@ -0,0 +1,68 @@
+package ngrok
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+}
+
+func TestClientCertificateFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	cfg := connectConfig{}
+	WithClientCertificateFiles(certFile, keyFile)(&cfg)
+
+	_, err := cfg.GetClientCertificate(&tls.CertificateRequestInfo{})
+	require.Error(t, err)
+
+	encodeKeys := map[string]func(*ecdsa.PrivateKey) (string, []byte, error){
+		"pkcs8": func(key *ecdsa.PrivateKey) (string, []byte, error) {
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			return "PRIVATE KEY", der, err
+		},
+		"ec": func(key *ecdsa.PrivateKey) (string, []byte, error) {
+			der, err := x509.MarshalECPrivateKey(key)
+			return "EC PRIVATE KEY", der, err
+		},
+	}
+	for name, encodeKey := range encodeKeys {
+		t.Run(name, func(t *testing.T) {
+			// the files are re-read every time
+			cert := selfSignedCert(t)
+			writePEM(t, certFile, "CERTIFICATE", cert.Certificate[0])
+			blockType, der, err := encodeKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+			require.NoError(t, err)
+			writePEM(t, keyFile, blockType, der)
+
+			loaded, err := cfg.GetClientCertificate(&tls.CertificateRequestInfo{})
+			require.NoError(t, err)
+			require.Equal(t, cert.Certificate, loaded.Certificate)
+		})
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	cert := selfSignedCert(t)
+	cfg := connectConfig{}
+	WithClientCertificate(cert)(&cfg)
+
+	got, err := cfg.GetClientCertificate(&tls.CertificateRequestInfo{})
+	require.NoError(t, err)
+	require.Equal(t, cert.Certificate, got.Certificate)
+
+	require.True(t, presentsClientCertificate(&tls.Config{GetClientCertificate: cfg.GetClientCertificate}))
+	require.True(t, presentsClientCertificate(&tls.Config{Certificates: []tls.Certificate{cert}}))
+	require.False(t, presentsClientCertificate(&tls.Config{}))
+}
@@ -0,0 +1,96 @@
+package ngrok
+
+import (
+	"context"
+	"time"
+)
+
+// How long command handlers have to respond to the ngrok service. The
+// protocol doesn't carry a deadline, so this is a fixed local bound rather
+// than how long the ngrok service actually waits.
+const commandTimeout = 30 * time.Second
+
+// StopRequest is a request from the ngrok service for the [Session] to stop.
+type StopRequest struct{}
+
+// RestartRequest is a request from the ngrok service for the application to
+// restart.
+type RestartRequest struct{}
+
+// UpdateRequest is a request from the ngrok service for the application to
+// update itself.
+type UpdateRequest struct {
+	// The version to update to. If empty, update to the latest version.
+	Version string
+	// Whether the operator permitted an update to a new major version.
+	PermitMajorVersion bool
+}
+
+// CommandResult is the outcome of handling a command from the ngrok service.
+type CommandResult struct {
+	// If set, the command failed and the error is reported to the ngrok
+	// dashboard or API.
+	Err error
+	// By default, the [Session] is closed once a Stop or Restart command
+	// succeeds. Set this to keep it open, for example while a new process
+	// takes over.
+	KeepSession bool
+	// If set, called once the response has been sent to the ngrok service,
+	// for work that must wait until then, such as restarting the process.
+	AfterResponse func()
+}
+
+// StopCommandHandler is the callback type for [WithStopCommandHandler]
+type StopCommandHandler func(ctx context.Context, sess Session, req StopRequest) CommandResult
+
+// RestartCommandHandler is the callback type for [WithRestartCommandHandler]
+type RestartCommandHandler func(ctx context.Context, sess Session, req RestartRequest) CommandResult
+
+// UpdateCommandHandler is the callback type for [WithUpdateCommandHandler]
+type UpdateCommandHandler func(ctx context.Context, sess Session, req UpdateRequest) CommandResult
+
+// WithStopCommandHandler configures a function which is called when the ngrok
+// service requests that this [Session] stops. It replaces any handler set with
+// [WithStopHandler].
+//
+// The context passed to the handler is done [commandTimeout] after the
+// command arrives, or when the [Session] closes. Unless the result asks to
+// keep it, the [Session] is closed after a successful response has been sent.
+func WithStopCommandHandler(handler StopCommandHandler) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.StopHandler = handler
+	}
+}
+
+// WithRestartCommandHandler configures a function which is called when the
+// ngrok service requests that the application restarts. It replaces any
+// handler set with [WithRestartHandler].
+//
+// The context passed to the handler is done [commandTimeout] after the
+// command arrives, or when the [Session] closes. Unless the result asks to
+// keep it, the [Session] is closed after a successful response has been sent.
+func WithRestartCommandHandler(handler RestartCommandHandler) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.RestartHandler = handler
+	}
+}
+
+// WithUpdateCommandHandler configures a function which is called when the
+// ngrok service requests that the application updates, with the version the
+// operator asked for. It replaces any handler set with [WithUpdateHandler].
+//
+// The context passed to the handler is done [commandTimeout] after the
+// command arrives, or when the [Session] closes. Long-running updates should
+// respond first and continue asynchronously.
+func WithUpdateCommandHandler(handler UpdateCommandHandler) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.UpdateHandler = handler
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,73 @@
+package ngrok
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inconshreveable/log15/v3"
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+type closeCountingSession struct {
+	Session
+	closed int
+}
+
+func (s *closeCountingSession) Close() error {
+	s.closed++
+	return nil
+}
+
+func TestRemoteCommands(t *testing.T) {
+	sess := &closeCountingSession{}
+	var responses []any
+	respond := func(v any) error {
+		responses = append(responses, v)
+		return nil
+	}
+
+	var update UpdateRequest
+	cfg := connectConfig{}
+	WithStopHandler(func(ctx context.Context, sess Session) error {
+		return testError
+	})(&cfg)
+	WithRestartCommandHandler(func(ctx context.Context, sess Session, req RestartRequest) CommandResult {
+		_, ok := ctx.Deadline()
+		require.True(t, ok)
+		return CommandResult{KeepSession: true}
+	})(&cfg)
+	WithUpdateCommandHandler(func(ctx context.Context, sess Session, req UpdateRequest) CommandResult {
+		update = req
+		return CommandResult{}
+	})(&cfg)
+
+	rc := remoteCallbackHandler{
+		Logger:         log15.New(),
+		ctx:            context.Background(),
+		sess:           sess,
+		stopHandler:    cfg.StopHandler,
+		restartHandler: cfg.RestartHandler,
+		updateHandler:  cfg.UpdateHandler,
+	}
+
+	rc.OnStop(&proto.Stop{}, respond)
+	require.Equal(t, &proto.StopResp{Error: testError.Error()}, responses[0])
+	require.Equal(t, 0, sess.closed)
+
+	rc.OnRestart(&proto.Restart{}, respond)
+	require.Equal(t, &proto.RestartResp{}, responses[1])
+	require.Equal(t, 0, sess.closed)
+
+	rc.OnUpdate(&proto.Update{Version: "2.0.0", PermitMajorVersion: true}, respond)
+	require.Equal(t, &proto.UpdateResp{}, responses[2])
+	require.Equal(t, UpdateRequest{Version: "2.0.0", PermitMajorVersion: true}, update)
+
+	rc.stopHandler = func(ctx context.Context, sess Session, req StopRequest) CommandResult {
+		return CommandResult{}
+	}
+	rc.OnStop(&proto.Stop{}, respond)
+	require.Equal(t, &proto.StopResp{}, responses[3])
+	require.Equal(t, 1, sess.closed)
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+type ACMEOption func(cfg *acmeOptions)
+
+// Options for obtaining certificates via ACME.
+type acmeOptions struct {
+	// The ACME directory to use. Defaults to Let's Encrypt.
+	DirectoryURL string
+	// Contact email for the ACME account.
+	Email string
+	// Storage for certificates and the account key. If nil, certificates are
+	// only kept in memory.
+	Cache autocert.Cache
+	// The HTTP client used to talk to the ACME server.
+	HTTPClient *http.Client
+	// Serve a self-signed certificate if one can't be obtained via ACME.
+	DevMode bool
+}
+
+// WithACMEDirectoryURL sets the URL of the ACME directory to obtain
+// certificates from. Defaults to the Let's Encrypt production directory.
+func WithACMEDirectoryURL(url string) ACMEOption {
+	return func(cfg *acmeOptions) {
+		cfg.DirectoryURL = url
+	}
+}
+
+// WithACMEEmail sets the contact email address for the ACME account.
+func WithACMEEmail(email string) ACMEOption {
+	return func(cfg *acmeOptions) {
+		cfg.Email = email
+	}
+}
+
+// WithACMECache sets the storage used for certificates and the ACME account
+// key. Use [autocert.DirCache] to persist them across restarts.
+// If unset, certificates are only kept in memory.
+func WithACMECache(cache autocert.Cache) ACMEOption {
+	return func(cfg *acmeOptions) {
+		cfg.Cache = cache
+	}
+}
+
+// WithACMEHTTPClient sets the HTTP client used to talk to the ACME server.
+// This is mostly useful for trusting the CA of a local ACME test server.
+func WithACMEHTTPClient(client *http.Client) ACMEOption {
+	return func(cfg *acmeOptions) {
+		cfg.HTTPClient = client
+	}
+}
+
+// WithACMEDevMode serves a self-signed certificate whenever one can't be
+// obtained via ACME. Do not use this in production.
+func WithACMEDevMode() ACMEOption {
+	return func(cfg *acmeOptions) {
+		cfg.DevMode = true
+	}
+}
+
+// WithTLSTerminationACME terminates TLS in the library using certificates
+// obtained and renewed via ACME for the domain set with [WithDomain].
+//
+// Certificates are requested using the TLS-ALPN-01 challenge, which is served
+// over the tunnel itself.
+func WithTLSTerminationACME(opts ...ACMEOption) TLSTerminationOption {
+	return TLSTerminationOption(func(cfg *tlsTermination) {
+		acmeCfg := &acmeOptions{}
+		for _, opt := range opts {
+			opt(acmeCfg)
+		}
+		cfg.location = TLSAtLibrary
+		cfg.acme = acmeCfg
+	})
+}
+
+func (cfg *acmeOptions) tlsConfig(domain string) (*tls.Config, error) {
+	if domain == "" {
+		return nil, errors.New("ACME certificates require a domain, set one with WithDomain")
+	}
+
+	mgr := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domain),
+		Cache:      cfg.Cache,
+		Email:      cfg.Email,
+	}
+	if cfg.DirectoryURL != "" || cfg.HTTPClient != nil {
+		mgr.Client = &acme.Client{
+			DirectoryURL: cfg.DirectoryURL,
+			HTTPClient:   cfg.HTTPClient,
+		}
+	}
+
+	getCertificate := mgr.GetCertificate
+	if cfg.DevMode {
+		var (
+			once       sync.Once
+			selfSigned *tls.Certificate
+			selfErr    error
+		)
+		getCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := mgr.GetCertificate(hello)
+			// a self-signed certificate can't answer a challenge
+			if err == nil || isChallengeHello(hello) {
+				return cert, err
+			}
+			once.Do(func() {
+				selfSigned, selfErr = selfSignedCertificate(domain)
+			})
+			return selfSigned, selfErr
+		}
+	}
+
+	return &tls.Config{
+		GetCertificate: getCertificate,
+		// Only offer protocols that a plain net.Listener consumer can speak,
+		// plus the one needed for the TLS-ALPN-01 challenge.
+		NextProtos: []string{"http/1.1", acme.ALPNProto},
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// Reports whether hello is from an ACME server validating a TLS-ALPN-01
+// challenge.
+func isChallengeHello(hello *tls.ClientHelloInfo) bool {
+	for _, proto := range hello.SupportedProtos {
+		if proto == acme.ALPNProto {
+			return true
+		}
+	}
+	return false
+}
+
+func selfSignedCertificate(domain string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
@@ -1,25 +1,144 @@
 package config
 
-import "golang.ngrok.com/ngrok/internal/pb"
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.uber.org/multierr"
+	"golang.org/x/crypto/bcrypt"
+
+	"golang.ngrok.com/ngrok/internal/pb"
+)
+
+const (
+	basicAuthMinPasswordLen = 8
+	basicAuthMaxPasswordLen = 128
+	// The fewest different characters a cleartext password may contain.
+	basicAuthMinDistinctChars = 4
+)
+
+// Passwords that are rejected regardless of their length, since they're the
+// first ones an attacker will try.
+var commonPasswords = map[string]bool{
+	"password":   true,
+	"password1":  true,
+	"password12": true,
+	"passw0rd":   true,
+	"12345678":   true,
+	"123456789":  true,
+	"1234567890": true,
+	"87654321":   true,
+	"qwertyui":   true,
+	"qwerty123":  true,
+	"1q2w3e4r":   true,
+	"abcd1234":   true,
+	"iloveyou":   true,
+	"letmein1":   true,
+	"baseball":   true,
+	"football":   true,
+	"sunshine":   true,
+	"princess":   true,
+	"trustno1":   true,
+	"changeme":   true,
+}
 
 // BasicAuth is a set of credentials for basic authentication.
 type basicAuth struct {
 	// The username for basic authentication.
 	Username string
 	// The password for basic authentication.
-	// Must be at least eight characters.
+	// Must be at least eight characters, and not weak.
 	Password string
+	// The bcrypt hash of the password for basic authentication.
+	// Takes precedence over Password if set.
+	HashedPassword []byte
+	// If set, the password is resolved from this source instead.
+	PasswordSource SecretSource
+}
+
+func (ba basicAuth) resolveSecrets(ctx context.Context) (basicAuth, error) {
+	if ba.PasswordSource == nil {
+		return ba, nil
+	}
+	password, err := ba.PasswordSource.Secret(ctx)
+	if err != nil {
+		return ba, fmt.Errorf("resolving basic auth password for %q: %w", ba.Username, err)
+	}
+	ba.Password = string(password)
+	ba.PasswordSource = nil
+	return ba, ba.validate()
 }
 
 func (ba basicAuth) toProtoConfig() *pb.MiddlewareConfiguration_BasicAuthCredential {
+	if ba.HashedPassword != nil {
+		return &pb.MiddlewareConfiguration_BasicAuthCredential{
+			HashedPassword: ba.HashedPassword,
+			Username:       ba.Username,
+		}
+	}
 	return &pb.MiddlewareConfiguration_BasicAuthCredential{
 		CleartextPassword: ba.Password,
 		Username:          ba.Username,
 	}
 }
 
+func (ba basicAuth) validate() error {
+	if ba.Username == "" {
+		return errors.New("basic auth username must not be empty")
+	}
+	if ba.PasswordSource != nil {
+		// validated once resolved
+		return nil
+	}
+	if ba.HashedPassword != nil {
+		if _, err := bcrypt.Cost(ba.HashedPassword); err != nil {
+			return fmt.Errorf("basic auth password for %q is not a bcrypt hash: %w", ba.Username, err)
+		}
+		return nil
+	}
+	if len(ba.Password) < basicAuthMinPasswordLen {
+		return fmt.Errorf("basic auth password for %q must be at least %d characters", ba.Username, basicAuthMinPasswordLen)
+	}
+	if len(ba.Password) > basicAuthMaxPasswordLen {
+		return fmt.Errorf("basic auth password for %q must be at most %d characters", ba.Username, basicAuthMaxPasswordLen)
+	}
+	if reason := weakPassword(ba.Username, ba.Password); reason != "" {
+		return fmt.Errorf("basic auth password for %q is too weak: %s", ba.Username, reason)
+	}
+	return nil
+}
+
+// Returns why password is too easy to guess, or an empty string if it isn't.
+func weakPassword(username, password string) string {
+	lower := strings.ToLower(password)
+	if lower == strings.ToLower(username) {
+		return "it is the same as the username"
+	}
+	if commonPasswords[lower] {
+		return "it is a commonly used password"
+	}
+	distinct := make(map[rune]struct{})
+	for _, r := range password {
+		distinct[r] = struct{}{}
+	}
+	if len(distinct) < basicAuthMinDistinctChars {
+		return fmt.Sprintf("it must contain at least %d different characters", basicAuthMinDistinctChars)
+	}
+	return ""
+}
+
 // WithBasicAuth adds the provided credentials to the list of basic
 // authentication credentials.
+//
+// The password is sent to the ngrok service in cleartext and must be between
+// 8 and 128 characters long. Weak passwords are rejected: those that match
+// the username, are commonly used, or have fewer than 4 different
+// characters. Prefer [WithBasicAuthHashed] to avoid sending the password.
 func WithBasicAuth(username, password string) HTTPEndpointOption {
 	return httpOptionFunc(func(cfg *httpOptions) {
 		cfg.BasicAuth = append(cfg.BasicAuth,
@@ -29,3 +148,80 @@ func WithBasicAuth(username, password string) HTTPEndpointOption {
 			})
 	})
 }
+
+// WithBasicAuthSource adds credentials to the list of basic authentication
+// credentials with a password that is resolved from src each time the tunnel
+// is bound. The same restrictions as [WithBasicAuth] apply to the password.
+func WithBasicAuthSource(username string, src SecretSource) HTTPEndpointOption {
+	return httpOptionFunc(func(cfg *httpOptions) {
+		cfg.BasicAuth = append(cfg.BasicAuth,
+			basicAuth{
+				Username:       username,
+				PasswordSource: src,
+			})
+	})
+}
+
+// WithBasicAuthHashed adds the provided credentials to the list of basic
+// authentication credentials. The hash must be a bcrypt hash of the password,
+// such as one generated by [bcrypt.GenerateFromPassword] or `htpasswd -B`.
+func WithBasicAuthHashed(username string, hash []byte) HTTPEndpointOption {
+	return httpOptionFunc(func(cfg *httpOptions) {
+		cfg.BasicAuth = append(cfg.BasicAuth,
+			basicAuth{
+				Username:       username,
+				HashedPassword: hash,
+			})
+	})
+}
+
+// WithBasicAuthFile adds the credentials from an htpasswd-style file to the
+// list of basic authentication credentials. Each line of the file must have
+// the form `username:hash`, where hash is a bcrypt hash. Blank lines and lines
+// starting with `#` are ignored.
+//
+// Errors reading the file are reported when the tunnel is started.
+func WithBasicAuthFile(path string) HTTPEndpointOption {
+	return httpOptionFunc(func(cfg *httpOptions) {
+		f, err := os.Open(path)
+		if err != nil {
+			cfg.err = multierr.Append(cfg.err, err)
+			return
+		}
+		defer f.Close()
+
+		creds, err := parseHtpasswd(f)
+		if err != nil {
+			cfg.err = multierr.Append(cfg.err, fmt.Errorf("%s: %w", path, err))
+			return
+		}
+		cfg.BasicAuth = append(cfg.BasicAuth, creds...)
+	})
+}
+
+func parseHtpasswd(r io.Reader) ([]basicAuth, error) {
+	var creds []basicAuth
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected username:hash", lineNo)
+		}
+		if !strings.HasPrefix(hash, "$2") {
+			return nil, fmt.Errorf("line %d: unsupported hash for %q, only bcrypt is supported", lineNo, username)
+		}
+
+		creds = append(creds, basicAuth{
+			Username:       username,
+			HashedPassword: []byte(hash),
+		})
+	}
+
+	return creds, scanner.Err()
+}
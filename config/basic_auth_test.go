@@ -1,9 +1,13 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
 	"golang.ngrok.com/ngrok/internal/pb"
 	"golang.ngrok.com/ngrok/internal/tunnel/proto"
@@ -46,3 +50,73 @@ func TestBasicAuth(t *testing.T) {
 
 	cases.runAll(t)
 }
+
+func TestBasicAuthHashed(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("foobarbaz"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	cases := testCases[httpOptions, proto.HTTPEndpoint]{
+		{
+			name: "hashed",
+			opts: HTTPEndpoint(WithBasicAuthHashed("foo", hash)),
+			expectOpts: func(t *testing.T, opts *proto.HTTPEndpoint) {
+				require.NotNil(t, opts.BasicAuth)
+				require.Len(t, opts.BasicAuth.Credentials, 1)
+				require.Contains(t, opts.BasicAuth.Credentials, &pb.MiddlewareConfiguration_BasicAuthCredential{
+					Username:       "foo",
+					HashedPassword: hash,
+				})
+			},
+		},
+	}
+
+	cases.runAll(t)
+}
+
+func TestBasicAuthValidate(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("foobarbaz"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	validate := func(opts ...HTTPEndpointOption) error {
+		return HTTPEndpoint(opts...).(httpOptions).Validate()
+	}
+
+	require.NoError(t, validate())
+	require.NoError(t, validate(WithBasicAuth("foo", "foobarbaz")))
+	require.NoError(t, validate(WithBasicAuthHashed("foo", hash)))
+
+	require.Error(t, validate(WithBasicAuth("foo", "bar")), "too short")
+	require.Error(t, validate(WithBasicAuth("foo", strings.Repeat("a", 129))), "too long")
+	require.Error(t, validate(WithBasicAuth("", "foobarbaz")), "missing username")
+	require.Error(t, validate(WithBasicAuthHashed("foo", []byte("foobarbaz"))), "not a bcrypt hash")
+
+	require.Error(t, validate(WithBasicAuth("foo", "aaaaaaaa")), "too few different characters")
+	require.Error(t, validate(WithBasicAuth("foo", "abababab")), "too few different characters")
+	require.Error(t, validate(WithBasicAuth("foo", "Password1")), "common password")
+	require.Error(t, validate(WithBasicAuth("administrator", "Administrator")), "same as username")
+}
+
+func TestBasicAuthFile(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("foobarbaz"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "htpasswd")
+	contents := "# users\n\nfoo:" + string(hash) + "\nspam:" + string(hash) + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+
+	opts := HTTPEndpoint(WithBasicAuthFile(path)).(httpOptions)
+	require.NoError(t, opts.Validate())
+	protoOpts, err := opts.toProtoConfig()
+	require.NoError(t, err)
+	require.Len(t, protoOpts.BasicAuth.Credentials, 2)
+	require.Equal(t, "foo", protoOpts.BasicAuth.Credentials[0].Username)
+	require.Equal(t, hash, protoOpts.BasicAuth.Credentials[0].HashedPassword)
+	require.Empty(t, protoOpts.BasicAuth.Credentials[0].CleartextPassword)
+
+	md5Path := filepath.Join(dir, "htpasswd-md5")
+	require.NoError(t, os.WriteFile(md5Path, []byte("foo:$apr1$abc$def\n"), 0600))
+	require.Error(t, HTTPEndpoint(WithBasicAuthFile(md5Path)).(httpOptions).Validate())
+
+	require.Error(t, HTTPEndpoint(WithBasicAuthFile(filepath.Join(dir, "missing"))).(httpOptions).Validate())
+}
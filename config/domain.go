@@ -3,9 +3,11 @@ package config
 type domainOption string
 
 // WithDomain sets the fully-qualified domain name for this edge.
+// For SSH edges, this is the public hostname of the endpoint.
 func WithDomain(name string) interface {
 	HTTPEndpointOption
 	TLSEndpointOption
+	SSHEndpointOption
 } {
 	return domainOption(name)
 }
@@ -18,6 +20,10 @@ func (opt domainOption) ApplyTLS(opts *tlsOptions) {
 	opts.Domain = string(opt)
 }
 
+func (opt domainOption) ApplySSH(opts *sshOptions) {
+	opts.Hostname = string(opt)
+}
+
 type hostnameOption string
 
 // WithHostname sets the hostname for this edge.
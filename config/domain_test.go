@@ -46,4 +46,7 @@ func TestDomain(t *testing.T) {
 	testDomain[tlsOptions](t, TLSEndpoint, func(opts *proto.TLSEndpoint) string {
 		return opts.Domain
 	})
+	testDomain[sshOptions](t, SSHEndpoint, func(opts *proto.SSHOptions) string {
+		return opts.Hostname
+	})
 }
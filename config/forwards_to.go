@@ -13,6 +13,7 @@ func WithForwardsTo(meta string) interface {
 	LabeledTunnelOption
 	TCPEndpointOption
 	TLSEndpointOption
+	SSHEndpointOption
 } {
 	return forwardsToOption(meta)
 }
@@ -35,6 +36,10 @@ func (fwd forwardsToOption) ApplyLabeled(cfg *labeledOptions) {
 	cfg.commonOpts.ForwardsTo = string(fwd)
 }
 
+func (fwd forwardsToOption) ApplySSH(cfg *sshOptions) {
+	cfg.commonOpts.ForwardsTo = string(fwd)
+}
+
 func defaultForwardsTo() string {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -32,4 +32,5 @@ func TestForwardsTo(t *testing.T) {
 	testForwardsTo[tlsOptions](t, TLSEndpoint)
 	testForwardsTo[tcpOptions](t, TCPEndpoint)
 	testForwardsTo[labeledOptions](t, LabeledTunnel)
+	testForwardsTo[sshOptions](t, SSHEndpoint)
 }
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"context"
 	"crypto/x509"
+	"fmt"
 	"net/http"
 
+	"go.uber.org/multierr"
+
 	"golang.ngrok.com/ngrok/internal/pb"
 	"golang.ngrok.com/ngrok/internal/tunnel/proto"
 )
@@ -74,9 +78,15 @@ type httpOptions struct {
 	// WebhookVerification configuration.
 	// If nil, WebhookVerification is disabled.
 	WebhookVerification *webhookVerification
+
+	// If set, secrets are sealed before being sent to the ngrok service.
+	sealer *secretSealer
+
+	// Errors encountered while applying options.
+	err error
 }
 
-func (cfg *httpOptions) toProtoConfig() *proto.HTTPEndpoint {
+func (cfg *httpOptions) toProtoConfig() (*proto.HTTPEndpoint, error) {
 	opts := &proto.HTTPEndpoint{
 		Domain:    cfg.Domain,
 		Hostname:  cfg.Hostname,
@@ -109,12 +119,19 @@ func (cfg *httpOptions) toProtoConfig() *proto.HTTPEndpoint {
 			opts.BasicAuth.Credentials = append(opts.BasicAuth.Credentials, c.toProtoConfig())
 		}
 	}
-	opts.OAuth = cfg.OAuth.toProtoConfig()
-	opts.OIDC = cfg.OIDC.toProtoConfig()
-	opts.WebhookVerification = cfg.WebhookVerification.toProtoConfig()
+	var err error
+	if opts.OAuth, err = cfg.OAuth.toProtoConfig(cfg.sealer); err != nil {
+		return nil, fmt.Errorf("OAuth client secret: %w", err)
+	}
+	if opts.OIDC, err = cfg.OIDC.toProtoConfig(cfg.sealer); err != nil {
+		return nil, fmt.Errorf("OIDC client secret: %w", err)
+	}
+	if opts.WebhookVerification, err = cfg.WebhookVerification.toProtoConfig(cfg.sealer); err != nil {
+		return nil, fmt.Errorf("webhook verification secret: %w", err)
+	}
 	opts.IPRestriction = cfg.commonOpts.CIDRRestrictions.toProtoConfig()
 
-	return opts
+	return opts, nil
 }
 
 func (cfg httpOptions) tunnelOptions() {}
@@ -133,10 +150,76 @@ func (cfg httpOptions) Proto() string {
 	}
 	return string(cfg.Scheme)
 }
+
+// Opts returns the options to bind the tunnel with, or nil if a secret
+// can't be sealed. Tunnels are bound with ResolveOpts, which reports why.
 func (cfg httpOptions) Opts() any {
+	opts, _ := cfg.toProtoConfig()
+	return opts
+}
+
+// ResolveOpts resolves any secret sources and returns the options to bind the
+// tunnel with.
+func (cfg httpOptions) ResolveOpts(ctx context.Context) (any, error) {
+	var err error
+
+	basicAuth := make([]basicAuth, len(cfg.BasicAuth))
+	for i, c := range cfg.BasicAuth {
+		if basicAuth[i], err = c.resolveSecrets(ctx); err != nil {
+			return nil, err
+		}
+	}
+	cfg.BasicAuth = basicAuth
+
+	if cfg.OAuth, err = cfg.OAuth.resolveSecrets(ctx); err != nil {
+		return nil, err
+	}
+	if cfg.OIDC, err = cfg.OIDC.resolveSecrets(ctx); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookVerification, err = cfg.WebhookVerification.resolveSecrets(ctx); err != nil {
+		return nil, err
+	}
+
 	return cfg.toProtoConfig()
 }
 
+// HasSecretSources reports whether any options are resolved from a
+// [SecretSource], and so must be resolved again each time the tunnel is
+// re-bound.
+func (cfg httpOptions) HasSecretSources() bool {
+	for _, c := range cfg.BasicAuth {
+		if c.PasswordSource != nil {
+			return true
+		}
+	}
+	return (cfg.OAuth != nil && cfg.OAuth.ClientSecretSource != nil) ||
+		(cfg.OIDC != nil && cfg.OIDC.ClientSecretSource != nil) ||
+		(cfg.WebhookVerification != nil && cfg.WebhookVerification.SecretSource != nil)
+}
+
+// ResolveSecretsInto resolves any secret sources, and returns a copy of opts,
+// the options the tunnel was last bound with, with the options that hold
+// secrets replaced. Anything the ngrok service assigned, such as the
+// hostname, is kept.
+func (cfg httpOptions) ResolveSecretsInto(ctx context.Context, opts any) (any, error) {
+	resolved, err := cfg.ResolveOpts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, ok := opts.(*proto.HTTPEndpoint)
+	if !ok || current == nil {
+		return resolved, nil
+	}
+	fresh := resolved.(*proto.HTTPEndpoint)
+	merged := *current
+	merged.BasicAuth = fresh.BasicAuth
+	merged.OAuth = fresh.OAuth
+	merged.OIDC = fresh.OIDC
+	merged.WebhookVerification = fresh.WebhookVerification
+	return &merged, nil
+}
+
 func (cfg httpOptions) Labels() map[string]string {
 	return nil
 }
@@ -145,6 +228,16 @@ func (cfg httpOptions) HTTPServer() *http.Server {
 	return cfg.httpServer
 }
 
+// Validate reports configuration errors that can be detected before starting
+// the tunnel.
+func (cfg httpOptions) Validate() error {
+	err := cfg.err
+	for _, c := range cfg.BasicAuth {
+		err = multierr.Append(err, c.validate())
+	}
+	return err
+}
+
 // compile-time check that we're implementing the proper interfaces.
 var _ interface {
 	tunnelConfigPrivate
@@ -3,6 +3,8 @@ package config
 import (
 	"net/http"
 
+	"golang.org/x/crypto/ssh"
+
 	"golang.ngrok.com/ngrok/internal/tunnel/proto"
 )
 
@@ -35,6 +37,8 @@ type labeledOptions struct {
 
 	// An HTTP Server to run traffic on
 	httpServer *http.Server
+	// An SSH server to run traffic on
+	sshServer *sshServerOption
 }
 
 // WithLabel adds a label to this tunnel's set of label, value pairs.
@@ -72,6 +76,10 @@ func (cfg labeledOptions) HTTPServer() *http.Server {
 	return cfg.httpServer
 }
 
+func (cfg labeledOptions) SSHServer() (*ssh.ServerConfig, SSHConnHandler) {
+	return cfg.sshServer.get()
+}
+
 // compile-time check that we're implementing the proper interfaces.
 var _ interface {
 	tunnelConfigPrivate
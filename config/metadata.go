@@ -6,6 +6,7 @@ func WithMetadata(meta string) interface {
 	TCPEndpointOption
 	TLSEndpointOption
 	LabeledTunnelOption
+	SSHEndpointOption
 } {
 	return metadataOption(meta)
 }
@@ -27,3 +28,7 @@ func (meta metadataOption) ApplyTLS(cfg *tlsOptions) {
 func (meta metadataOption) ApplyLabeled(cfg *labeledOptions) {
 	cfg.Metadata = string(meta)
 }
+
+func (meta metadataOption) ApplySSH(cfg *sshOptions) {
+	cfg.Metadata = string(meta)
+}
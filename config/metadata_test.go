@@ -36,4 +36,5 @@ func TestMetadata(t *testing.T) {
 	testMetadata[tlsOptions](t, TLSEndpoint)
 	testMetadata[tcpOptions](t, TCPEndpoint)
 	testMetadata[labeledOptions](t, LabeledTunnel)
+	testMetadata[sshOptions](t, SSHEndpoint)
 }
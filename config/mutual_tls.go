@@ -38,3 +38,21 @@ func (cfg mutualTLSEndpointOption) toProtoConfig() *pb.MiddlewareConfiguration_M
 	}
 	return opts
 }
+
+type mutualTLSAtAgentOption struct {
+	pool *x509.CertPool
+}
+
+// WithMutualTLSAtAgent verifies client certificates in the library against
+// the provided pool, rather than at the ngrok edge. The ngrok service never
+// sees the client certificates.
+//
+// This requires TLS termination in the library, see [TLSAtLibrary]. Peer
+// certificates are available from the ngrok.Conn returned by Accept.
+func WithMutualTLSAtAgent(pool *x509.CertPool) TLSEndpointOption {
+	return mutualTLSAtAgentOption{pool}
+}
+
+func (opt mutualTLSAtAgentOption) ApplyTLS(opts *tlsOptions) {
+	opts.MutualTLSAtAgent = opt.pool
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"context"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"testing"
@@ -61,3 +63,51 @@ func TestMutualTLS(t *testing.T) {
 		return opts.MutualTLSAtEdge
 	})
 }
+
+func TestMutualTLSAtAgent(t *testing.T) {
+	certPEM, keyPEM := testKeyPair(t, "example.com")
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ngrokCA)
+
+	cases := testCases[tlsOptions, proto.TLSEndpoint]{
+		{
+			name: "absent",
+			opts: TLSEndpoint(),
+			expectOpts: func(t *testing.T, opts *proto.TLSEndpoint) {
+				require.False(t, opts.MutualTLSAtAgent)
+				require.Nil(t, opts.MutualTLSAtEdge)
+			},
+		},
+		{
+			name: "with mtls at agent",
+			opts: TLSEndpoint(
+				WithTLSTermination(
+					WithTLSTerminationAt(TLSAtLibrary),
+					WithTLSTerminationKeyPair(certPEM, keyPEM),
+				),
+				WithMutualTLSAtAgent(pool),
+			),
+			expectOpts: func(t *testing.T, opts *proto.TLSEndpoint) {
+				require.True(t, opts.MutualTLSAtAgent)
+				require.Nil(t, opts.MutualTLSAtEdge)
+				require.Nil(t, opts.TLSTermination)
+			},
+		},
+	}
+
+	cases.runAll(t)
+
+	tlsCfg, err := TLSEndpoint(
+		WithTLSTermination(
+			WithTLSTerminationAt(TLSAtLibrary),
+			WithTLSTerminationKeyPair(certPEM, keyPEM),
+		),
+		WithMutualTLSAtAgent(pool),
+	).(tlsOptions).TLSTerminationConfig(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, tlsCfg.ClientAuth)
+	require.Equal(t, pool, tlsCfg.ClientCAs)
+
+	_, err = TLSEndpoint(WithMutualTLSAtAgent(pool)).(tlsOptions).TLSTerminationConfig(context.Background())
+	require.Error(t, err, "mutual TLS at the agent requires termination in the library")
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"context"
+	"fmt"
+
 	"golang.ngrok.com/ngrok/internal/pb"
 	"golang.ngrok.com/ngrok/internal/tunnel/proto"
 )
@@ -21,6 +24,8 @@ type oauthOptions struct {
 	ClientID string
 	// OAuth custom app secret
 	ClientSecret proto.ObfuscatedString
+	// If set, the custom app secret is resolved from this source instead.
+	ClientSecretSource SecretSource
 }
 
 // Construct a new OAuth provider with the given name.
@@ -44,6 +49,14 @@ func WithOAuthClientSecret(secret string) OAuthOption {
 	}
 }
 
+// WithOAuthClientSecretSource provides a client secret for custom OAuth apps
+// that is resolved from src each time the tunnel is bound.
+func WithOAuthClientSecretSource(src SecretSource) OAuthOption {
+	return func(cfg *oauthOptions) {
+		cfg.ClientSecretSource = src
+	}
+}
+
 // Append email addresses to the list of allowed emails.
 func WithAllowOAuthEmail(addr ...string) OAuthOption {
 	return func(cfg *oauthOptions) {
@@ -65,19 +78,38 @@ func WithOAuthScope(scope ...string) OAuthOption {
 	}
 }
 
-func (oauth *oauthOptions) toProtoConfig() *pb.MiddlewareConfiguration_OAuth {
+func (oauth *oauthOptions) resolveSecrets(ctx context.Context) (*oauthOptions, error) {
+	if oauth == nil || oauth.ClientSecretSource == nil {
+		return oauth, nil
+	}
+	secret, err := oauth.ClientSecretSource.Secret(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolving OAuth client secret: %w", err)
+	}
+	resolved := *oauth
+	resolved.ClientSecret = proto.ObfuscatedString(secret)
+	return &resolved, nil
+}
+
+func (oauth *oauthOptions) toProtoConfig(sealer *secretSealer) (*pb.MiddlewareConfiguration_OAuth, error) {
 	if oauth == nil {
-		return nil
+		return nil, nil
 	}
 
-	return &pb.MiddlewareConfiguration_OAuth{
-		Provider:     string(oauth.Provider),
-		ClientId:     oauth.ClientID,
-		ClientSecret: oauth.ClientSecret.PlainText(),
-		AllowEmails:  oauth.AllowEmails,
-		AllowDomains: oauth.AllowDomains,
-		Scopes:       oauth.Scopes,
+	secret, sealedSecret, err := sealer.sealString(oauth.ClientSecret.PlainText())
+	if err != nil {
+		return nil, err
 	}
+
+	return &pb.MiddlewareConfiguration_OAuth{
+		Provider:           string(oauth.Provider),
+		ClientId:           oauth.ClientID,
+		ClientSecret:       secret,
+		SealedClientSecret: sealedSecret,
+		AllowEmails:        oauth.AllowEmails,
+		AllowDomains:       oauth.AllowDomains,
+		Scopes:             oauth.Scopes,
+	}, nil
 }
 
 // WithOAuth configures this edge with the the given OAuth provider.
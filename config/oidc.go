@@ -1,6 +1,9 @@
 package config
 
 import (
+	"context"
+	"fmt"
+
 	"golang.ngrok.com/ngrok/internal/pb"
 	"golang.ngrok.com/ngrok/internal/tunnel/proto"
 )
@@ -11,24 +14,45 @@ type oidcOptions struct {
 	IssuerURL    string
 	ClientID     string
 	ClientSecret proto.ObfuscatedString
-	AllowEmails  []string
-	AllowDomains []string
-	Scopes       []string
+	// If set, the client secret is resolved from this source instead.
+	ClientSecretSource SecretSource
+	AllowEmails        []string
+	AllowDomains       []string
+	Scopes             []string
+}
+
+func (oidc *oidcOptions) resolveSecrets(ctx context.Context) (*oidcOptions, error) {
+	if oidc == nil || oidc.ClientSecretSource == nil {
+		return oidc, nil
+	}
+	secret, err := oidc.ClientSecretSource.Secret(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolving OIDC client secret: %w", err)
+	}
+	resolved := *oidc
+	resolved.ClientSecret = proto.ObfuscatedString(secret)
+	return &resolved, nil
 }
 
-func (oidc *oidcOptions) toProtoConfig() *pb.MiddlewareConfiguration_OIDC {
+func (oidc *oidcOptions) toProtoConfig(sealer *secretSealer) (*pb.MiddlewareConfiguration_OIDC, error) {
 	if oidc == nil {
-		return nil
+		return nil, nil
 	}
 
-	return &pb.MiddlewareConfiguration_OIDC{
-		IssuerUrl:    oidc.IssuerURL,
-		ClientId:     oidc.ClientID,
-		ClientSecret: oidc.ClientSecret.PlainText(),
-		AllowEmails:  oidc.AllowEmails,
-		AllowDomains: oidc.AllowDomains,
-		Scopes:       oidc.Scopes,
+	secret, sealedSecret, err := sealer.sealString(oidc.ClientSecret.PlainText())
+	if err != nil {
+		return nil, err
 	}
+
+	return &pb.MiddlewareConfiguration_OIDC{
+		IssuerUrl:          oidc.IssuerURL,
+		ClientId:           oidc.ClientID,
+		ClientSecret:       secret,
+		SealedClientSecret: sealedSecret,
+		AllowEmails:        oidc.AllowEmails,
+		AllowDomains:       oidc.AllowDomains,
+		Scopes:             oidc.Scopes,
+	}, nil
 }
 
 // WithOIDC configures this edge with the the given OIDC provider.
@@ -49,6 +73,14 @@ func WithOIDC(issuerURL string, clientID string, clientSecret string, opts ...OI
 	})
 }
 
+// WithOIDCClientSecretSource resolves the OIDC client secret from src each
+// time the tunnel is bound, rather than using the one passed to [WithOIDC].
+func WithOIDCClientSecretSource(src SecretSource) OIDCOption {
+	return func(cfg *oidcOptions) {
+		cfg.ClientSecretSource = src
+	}
+}
+
 // Append email addresses to the list of allowed emails.
 func WithAllowOIDCEmail(addr ...string) OIDCOption {
 	return func(cfg *oidcOptions) {
@@ -20,6 +20,7 @@ func WithProxyProto(version ProxyProtoVersion) interface {
 	HTTPEndpointOption
 	TCPEndpointOption
 	TLSEndpointOption
+	SSHEndpointOption
 } {
 	return proxyProtoConfig(version)
 }
@@ -36,3 +37,7 @@ func (p proxyProtoConfig) ApplyTCP(cfg *tcpOptions) {
 func (p proxyProtoConfig) ApplyTLS(cfg *tlsOptions) {
 	cfg.ProxyProto = ProxyProtoVersion(p)
 }
+
+func (p proxyProtoConfig) ApplySSH(cfg *sshOptions) {
+	cfg.ProxyProto = ProxyProtoVersion(p)
+}
@@ -50,4 +50,7 @@ func TestProxyProto(t *testing.T) {
 	testProxyProto[tcpOptions](t, TCPEndpoint, func(opts *proto.TCPEndpoint) proto.ProxyProto {
 		return opts.ProxyProto
 	})
+	testProxyProto[sshOptions](t, SSHEndpoint, func(opts *proto.SSHOptions) proto.ProxyProto {
+		return opts.ProxyProto
+	})
 }
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"go.uber.org/multierr"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// Seals secrets so that only the ngrok service can decrypt them. A nil
+// sealer leaves secrets as they are.
+type secretSealer struct {
+	key *[32]byte
+	// The source of randomness for sealing. Defaults to [crypto/rand].
+	rand io.Reader
+}
+
+// Returns either the plaintext secret or its sealed form, depending on
+// whether sealing is enabled. Empty secrets are never sealed.
+func (s *secretSealer) seal(secret []byte) (plain []byte, sealed []byte, err error) {
+	if s == nil || len(secret) == 0 {
+		return secret, nil, nil
+	}
+	random := s.rand
+	if random == nil {
+		random = rand.Reader
+	}
+	sealed, err = box.SealAnonymous(nil, secret, s.key, random)
+	if err != nil {
+		// This only happens if the randomness source fails. Never fall back
+		// to sending the plaintext, or to sending nothing.
+		return nil, nil, fmt.Errorf("sealing secret: %w", err)
+	}
+	return nil, sealed, nil
+}
+
+func (s *secretSealer) sealString(secret string) (plain string, sealed []byte, err error) {
+	plainBytes, sealed, err := s.seal([]byte(secret))
+	return string(plainBytes), sealed, err
+}
+
+type secretSealingOption []byte
+
+// WithSecretSealing seals middleware secrets with the provided public key
+// before they leave the process. The key is the 32-byte Curve25519 public key
+// provided by the ngrok service; secrets are encrypted to it as NaCl anonymous
+// sealed boxes.
+//
+// This applies to OAuth and OIDC client secrets, webhook verification
+// secrets, and TLS termination private keys. Their plaintext is never sent to
+// the ngrok service or written to debug logs.
+func WithSecretSealing(publicKey []byte) interface {
+	HTTPEndpointOption
+	TLSEndpointOption
+} {
+	return secretSealingOption(publicKey)
+}
+
+func (opt secretSealingOption) sealer() (*secretSealer, error) {
+	if len(opt) != 32 {
+		return nil, fmt.Errorf("secret sealing key must be 32 bytes, got %d", len(opt))
+	}
+	key := new([32]byte)
+	copy(key[:], opt)
+	return &secretSealer{key: key}, nil
+}
+
+func (opt secretSealingOption) ApplyHTTP(cfg *httpOptions) {
+	sealer, err := opt.sealer()
+	if err != nil {
+		cfg.err = multierr.Append(cfg.err, err)
+		return
+	}
+	cfg.sealer = sealer
+}
+
+func (opt secretSealingOption) ApplyTLS(cfg *tlsOptions) {
+	sealer, err := opt.sealer()
+	if err != nil {
+		cfg.err = multierr.Append(cfg.err, err)
+		return
+	}
+	cfg.sealer = sealer
+}
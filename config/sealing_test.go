@@ -0,0 +1,125 @@
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+func TestSecretSealing(t *testing.T) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	open := func(t *testing.T, sealed []byte) string {
+		plain, ok := box.OpenAnonymous(nil, sealed, pub, priv)
+		require.True(t, ok, "sealed secret should open with the private key")
+		return string(plain)
+	}
+
+	httpCases := testCases[httpOptions, proto.HTTPEndpoint]{
+		{
+			name: "unsealed",
+			opts: HTTPEndpoint(
+				WithOAuth("google", WithOAuthClientSecret("oauth-secret")),
+				WithWebhookVerification("github", "webhook-secret"),
+			),
+			expectOpts: func(t *testing.T, opts *proto.HTTPEndpoint) {
+				require.Equal(t, "oauth-secret", opts.OAuth.ClientSecret)
+				require.Nil(t, opts.OAuth.SealedClientSecret)
+				require.Equal(t, "webhook-secret", opts.WebhookVerification.Secret)
+				require.Nil(t, opts.WebhookVerification.SealedSecret)
+			},
+		},
+		{
+			name: "sealed oauth and webhook",
+			opts: HTTPEndpoint(
+				WithSecretSealing(pub[:]),
+				WithOAuth("google", WithOAuthClientSecret("oauth-secret")),
+				WithWebhookVerification("github", "webhook-secret"),
+			),
+			expectOpts: func(t *testing.T, opts *proto.HTTPEndpoint) {
+				require.Empty(t, opts.OAuth.ClientSecret)
+				require.Equal(t, "oauth-secret", open(t, opts.OAuth.SealedClientSecret))
+				require.Empty(t, opts.WebhookVerification.Secret)
+				require.Equal(t, "webhook-secret", open(t, opts.WebhookVerification.SealedSecret))
+			},
+		},
+		{
+			name: "sealed oidc",
+			opts: HTTPEndpoint(
+				WithSecretSealing(pub[:]),
+				WithOIDC("https://example.com", "id", "oidc-secret"),
+			),
+			expectOpts: func(t *testing.T, opts *proto.HTTPEndpoint) {
+				require.Empty(t, opts.OIDC.ClientSecret)
+				require.Equal(t, "oidc-secret", open(t, opts.OIDC.SealedClientSecret))
+			},
+		},
+		{
+			name: "empty secrets aren't sealed",
+			opts: HTTPEndpoint(
+				WithSecretSealing(pub[:]),
+				WithOAuth("google"),
+			),
+			expectOpts: func(t *testing.T, opts *proto.HTTPEndpoint) {
+				require.Empty(t, opts.OAuth.ClientSecret)
+				require.Nil(t, opts.OAuth.SealedClientSecret)
+			},
+		},
+	}
+
+	httpCases.runAll(t)
+
+	tlsCases := testCases[tlsOptions, proto.TLSEndpoint]{
+		{
+			name: "sealed termination key",
+			opts: TLSEndpoint(
+				WithSecretSealing(pub[:]),
+				WithTLSTermination(WithTLSTerminationKeyPair([]byte("cert"), []byte("key"))),
+			),
+			expectOpts: func(t *testing.T, opts *proto.TLSEndpoint) {
+				require.Nil(t, opts.TLSTermination.Key)
+				require.Equal(t, "key", open(t, opts.TLSTermination.SealedKey))
+				require.Equal(t, []byte("cert"), opts.TLSTermination.Cert)
+			},
+		},
+	}
+
+	tlsCases.runAll(t)
+
+	require.Error(t, HTTPEndpoint(WithSecretSealing([]byte("short"))).(httpOptions).Validate())
+	require.Error(t, TLSEndpoint(WithSecretSealing([]byte("short"))).(tlsOptions).Validate())
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("no randomness")
+}
+
+func TestSecretSealingFailure(t *testing.T) {
+	pub, _, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	httpOpts := HTTPEndpoint(
+		WithSecretSealing(pub[:]),
+		WithWebhookVerification("github", "webhook-secret"),
+	).(httpOptions)
+	httpOpts.sealer.rand = failingReader{}
+	_, err = httpOpts.ResolveOpts(context.Background())
+	require.ErrorContains(t, err, "no randomness")
+
+	tlsOpts := TLSEndpoint(
+		WithSecretSealing(pub[:]),
+		WithTLSTermination(WithTLSTerminationKeyPair([]byte("cert"), []byte("key"))),
+	).(tlsOptions)
+	tlsOpts.sealer.rand = failingReader{}
+	_, err = tlsOpts.ResolveOpts(context.Background())
+	require.ErrorContains(t, err, "no randomness")
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// SecretSource provides a secret such as a password, client secret, or
+// private key on demand.
+//
+// Sources are resolved each time a tunnel is bound or a session
+// authenticates, so secrets rotated at the source are picked up on the next
+// rebind or reconnect.
+type SecretSource interface {
+	// Secret returns the current value of the secret.
+	Secret(ctx context.Context) ([]byte, error)
+}
+
+type envSecret string
+
+// SecretFromEnv reads a secret from the named environment variable. It is an
+// error for the variable to be unset.
+func SecretFromEnv(name string) SecretSource {
+	return envSecret(name)
+}
+
+func (name envSecret) Secret(_ context.Context) ([]byte, error) {
+	value, ok := os.LookupEnv(string(name))
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", string(name))
+	}
+	return []byte(value), nil
+}
+
+type fileSecret string
+
+// SecretFromFile reads a secret from the file at path. A single trailing
+// newline is removed.
+func SecretFromFile(path string) SecretSource {
+	return fileSecret(path)
+}
+
+func (path fileSecret) Secret(_ context.Context) ([]byte, error) {
+	contents, err := os.ReadFile(string(path))
+	if err != nil {
+		return nil, err
+	}
+	return trimNewline(contents), nil
+}
+
+type commandSecret struct {
+	name string
+	args []string
+}
+
+// SecretFromCommand runs the named program with the given arguments and uses
+// its standard output as the secret. A single trailing newline is removed.
+// The command is killed if the context used to resolve the secret is done.
+func SecretFromCommand(name string, args ...string) SecretSource {
+	return commandSecret{name, args}
+}
+
+func (cmd commandSecret) Secret(ctx context.Context) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, cmd.name, cmd.args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %s: %w", cmd.name, err)
+	}
+	return trimNewline(out), nil
+}
+
+func trimNewline(b []byte) []byte {
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	return bytes.TrimSuffix(b, []byte("\r"))
+}
+
+// Resolves a secret from src if it's set, otherwise returns the static value.
+func resolveSecret(ctx context.Context, src SecretSource, static []byte) ([]byte, error) {
+	if src == nil {
+		return static, nil
+	}
+	return src.Secret(ctx)
+}
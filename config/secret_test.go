@@ -0,0 +1,195 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+type staticSecret string
+
+func (s staticSecret) Secret(_ context.Context) ([]byte, error) {
+	return []byte(s), nil
+}
+
+type failingSecret struct{}
+
+func (failingSecret) Secret(_ context.Context) ([]byte, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestSecretSources(t *testing.T) {
+	ctx := context.Background()
+
+	t.Setenv("NGROK_TEST_SECRET", "from-env")
+	secret, err := SecretFromEnv("NGROK_TEST_SECRET").Secret(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "from-env", string(secret))
+
+	_, err = SecretFromEnv("NGROK_TEST_SECRET_UNSET").Secret(ctx)
+	require.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+	secret, err = SecretFromFile(path).Secret(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "from-file", string(secret))
+
+	_, err = SecretFromFile(filepath.Join(t.TempDir(), "missing")).Secret(ctx)
+	require.Error(t, err)
+
+	if _, err := exec.LookPath("echo"); err == nil {
+		secret, err = SecretFromCommand("echo", "from-command").Secret(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "from-command", string(secret))
+	}
+}
+
+func TestHTTPResolveOpts(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := HTTPEndpoint(
+		WithBasicAuthSource("user", staticSecret("s3cret-pass")),
+		WithOAuth("google",
+			WithOAuthClientID("id"),
+			WithOAuthClientSecretSource(staticSecret("oauth-secret")),
+		),
+		WithWebhookVerificationSource("twilio", staticSecret("webhook-secret")),
+	).(httpOptions)
+
+	require.NoError(t, cfg.Validate())
+
+	opts, err := cfg.ResolveOpts(ctx)
+	require.NoError(t, err)
+	endpoint := opts.(*proto.HTTPEndpoint)
+	require.Len(t, endpoint.BasicAuth.Credentials, 1)
+	require.Equal(t, "s3cret-pass", endpoint.BasicAuth.Credentials[0].CleartextPassword)
+	require.Equal(t, "oauth-secret", endpoint.OAuth.ClientSecret)
+	require.Equal(t, "webhook-secret", endpoint.WebhookVerification.Secret)
+
+	// Resolving must not leak into the original config.
+	require.Empty(t, cfg.BasicAuth[0].Password)
+	require.Empty(t, cfg.OAuth.ClientSecret)
+	require.Empty(t, cfg.WebhookVerification.Secret)
+
+	_, err = HTTPEndpoint(
+		WithBasicAuthSource("user", staticSecret("short")),
+	).(httpOptions).ResolveOpts(ctx)
+	require.Error(t, err)
+
+	_, err = HTTPEndpoint(
+		WithOIDC("https://example.com", "id", "",
+			WithOIDCClientSecretSource(failingSecret{}),
+		),
+	).(httpOptions).ResolveOpts(ctx)
+	require.Error(t, err)
+}
+
+func TestTLSResolveOpts(t *testing.T) {
+	ctx := context.Background()
+	certPEM, keyPEM := testKeyPair(t, "example.com")
+
+	cfg := TLSEndpoint(WithTLSTermination(
+		WithTLSTerminationKeyPairSource(staticSecret(certPEM), staticSecret(keyPEM)),
+	)).(tlsOptions)
+	opts, err := cfg.ResolveOpts(ctx)
+	require.NoError(t, err)
+	endpoint := opts.(*proto.TLSEndpoint)
+	require.Equal(t, certPEM, endpoint.TLSTermination.Cert)
+	require.Equal(t, keyPEM, endpoint.TLSTermination.Key)
+
+	_, err = TLSEndpoint(WithTLSTermination(
+		WithTLSTerminationKeyPairSource(failingSecret{}, staticSecret(keyPEM)),
+	)).(tlsOptions).ResolveOpts(ctx)
+	require.Error(t, err)
+
+	tlsCfg, err := TLSEndpoint(WithTLSTermination(
+		WithTLSTerminationAt(TLSAtLibrary),
+		WithTLSTerminationKeyPairSource(staticSecret(certPEM), staticSecret(keyPEM)),
+	)).(tlsOptions).TLSTerminationConfig(context.Background())
+	require.NoError(t, err)
+	cert, err := tlsCfg.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	require.Equal(t, "example.com", certDomain(t, cert))
+}
+
+func TestResolveSecretsInto(t *testing.T) {
+	ctx := context.Background()
+
+	require.False(t, HTTPEndpoint(WithBasicAuth("user", "s3cret-pass")).(httpOptions).HasSecretSources())
+	httpCfg := HTTPEndpoint(
+		WithDomain("example.com"),
+		WithBasicAuthSource("user", staticSecret("s3cret-pass")),
+	).(httpOptions)
+	require.True(t, httpCfg.HasSecretSources())
+
+	// the hostname the ngrok service assigned is kept
+	assigned := &proto.HTTPEndpoint{Hostname: "assigned.ngrok.app"}
+	opts, err := httpCfg.ResolveSecretsInto(ctx, assigned)
+	require.NoError(t, err)
+	endpoint := opts.(*proto.HTTPEndpoint)
+	require.Equal(t, "assigned.ngrok.app", endpoint.Hostname)
+	require.Equal(t, "s3cret-pass", endpoint.BasicAuth.Credentials[0].CleartextPassword)
+	require.Nil(t, assigned.BasicAuth, "the current options must not be modified")
+
+	certPEM, keyPEM := testKeyPair(t, "example.com")
+	require.False(t, TLSEndpoint().(tlsOptions).HasSecretSources())
+	tlsCfg := TLSEndpoint(WithTLSTermination(
+		WithTLSTerminationKeyPairSource(staticSecret(certPEM), staticSecret(keyPEM)),
+	)).(tlsOptions)
+	require.True(t, tlsCfg.HasSecretSources())
+	opts, err = tlsCfg.ResolveSecretsInto(ctx, &proto.TLSEndpoint{Hostname: "assigned.ngrok.app"})
+	require.NoError(t, err)
+	tlsEndpoint := opts.(*proto.TLSEndpoint)
+	require.Equal(t, "assigned.ngrok.app", tlsEndpoint.Hostname)
+	require.Equal(t, keyPEM, tlsEndpoint.TLSTermination.Key)
+}
+
+func certDomain(t *testing.T, cert *tls.Certificate) string {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.DNSNames[0]
+}
+
+func TestTLSTerminationKeyPairRotation(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeKeyPair := func(domain string) {
+		certPEM, keyPEM := testKeyPair(t, domain)
+		require.NoError(t, os.WriteFile(certPath, certPEM, 0600))
+		require.NoError(t, os.WriteFile(keyPath, keyPEM, 0600))
+	}
+	served := func(loader *keyPairLoader) string {
+		cert, err := loader.getCertificate(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		return certDomain(t, cert)
+	}
+
+	writeKeyPair("example.com")
+	tt := &tlsTermination{certSource: SecretFromFile(certPath), keySource: SecretFromFile(keyPath)}
+	cert, err := tt.keyPair(context.Background())
+	require.NoError(t, err)
+	loader := &keyPairLoader{tt: tt, cert: cert, loaded: time.Now()}
+	require.Equal(t, "example.com", served(loader))
+
+	// picked up once the current key pair is due to be refreshed
+	writeKeyPair("rotated.example.com")
+	require.Equal(t, "example.com", served(loader))
+	loader.loaded = time.Now().Add(-keyPairRefreshInterval)
+	require.Equal(t, "rotated.example.com", served(loader))
+
+	// a broken key pair doesn't replace a working one
+	require.NoError(t, os.WriteFile(keyPath, []byte("not a key"), 0600))
+	loader.loaded = time.Now().Add(-keyPairRefreshInterval)
+	require.Equal(t, "rotated.example.com", served(loader))
+}
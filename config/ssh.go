@@ -0,0 +1,89 @@
+package config
+
+import (
+	"golang.org/x/crypto/ssh"
+
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+type SSHEndpointOption interface {
+	ApplySSH(cfg *sshOptions)
+}
+
+type sshOptionFunc func(cfg *sshOptions)
+
+func (of sshOptionFunc) ApplySSH(cfg *sshOptions) {
+	of(cfg)
+}
+
+// Construct a new set of SSH tunnel options.
+func SSHEndpoint(opts ...SSHEndpointOption) Tunnel {
+	cfg := sshOptions{}
+	for _, opt := range opts {
+		opt.ApplySSH(&cfg)
+	}
+	return cfg
+}
+
+// The options for an SSH edge.
+type sshOptions struct {
+	// Common tunnel configuration options.
+	commonOpts
+
+	// The public hostname to request for this edge.
+	Hostname string
+	// Credentials to require at the ngrok edge.
+	Username string
+	Password proto.ObfuscatedString
+
+	// An SSH server to run on connections from the tunnel.
+	sshServer *sshServerOption
+}
+
+// WithSSHCredentials sets the username and password required by the ngrok edge
+// for this SSH endpoint.
+func WithSSHCredentials(username, password string) SSHEndpointOption {
+	return sshOptionFunc(func(cfg *sshOptions) {
+		cfg.Username = username
+		cfg.Password = proto.ObfuscatedString(password)
+	})
+}
+
+func (cfg *sshOptions) toProtoConfig() *proto.SSHOptions {
+	return &proto.SSHOptions{
+		Hostname:   cfg.Hostname,
+		Username:   cfg.Username,
+		Password:   cfg.Password.PlainText(),
+		ProxyProto: proto.ProxyProto(cfg.commonOpts.ProxyProto),
+	}
+}
+
+func (cfg sshOptions) tunnelOptions() {}
+
+func (cfg sshOptions) ForwardsTo() string {
+	return cfg.commonOpts.getForwardsTo()
+}
+func (cfg sshOptions) Extra() proto.BindExtra {
+	return proto.BindExtra{
+		Metadata: cfg.Metadata,
+	}
+}
+func (cfg sshOptions) Proto() string {
+	return "ssh"
+}
+func (cfg sshOptions) Opts() any {
+	return cfg.toProtoConfig()
+}
+func (cfg sshOptions) Labels() map[string]string {
+	return nil
+}
+
+func (cfg sshOptions) SSHServer() (*ssh.ServerConfig, SSHConnHandler) {
+	return cfg.sshServer.get()
+}
+
+// compile-time check that we're implementing the proper interfaces.
+var _ interface {
+	tunnelConfigPrivate
+	Tunnel
+} = (*sshOptions)(nil)
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHConnHandler handles an SSH connection accepted by a server configured
+// with [WithSSHServer]. The handler owns the connection and must service both
+// channels.
+type SSHConnHandler func(conn *ssh.ServerConn, chans <-chan ssh.NewChannel, reqs <-chan *ssh.Request)
+
+type sshServerOption struct {
+	Config  *ssh.ServerConfig
+	Handler SSHConnHandler
+}
+
+func (opt *sshServerOption) get() (*ssh.ServerConfig, SSHConnHandler) {
+	if opt == nil {
+		return nil, nil
+	}
+	return opt.Config, opt.Handler
+}
+
+func (opt *sshServerOption) ApplySSH(cfg *sshOptions) {
+	cfg.sshServer = opt
+}
+
+func (opt *sshServerOption) ApplyTCP(cfg *tcpOptions) {
+	cfg.sshServer = opt
+}
+
+func (opt *sshServerOption) ApplyLabeled(cfg *labeledOptions) {
+	cfg.sshServer = opt
+}
+
+// WithSSHServer runs an SSH server with the provided configuration on
+// connections accepted from the tunnel. Each connection that completes the SSH
+// handshake is passed to handler.
+//
+// If handler is nil, all channel requests are rejected and the connection is
+// closed.
+func WithSSHServer(cfg *ssh.ServerConfig, handler SSHConnHandler) interface {
+	SSHEndpointOption
+	TCPEndpointOption
+	LabeledTunnelOption
+} {
+	return &sshServerOption{Config: cfg, Handler: handler}
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func testSSHServer[T tunnelConfigPrivate, OT any](t *testing.T,
+	makeOpts func(...OT) Tunnel,
+) {
+	optsFunc := func(opts ...any) Tunnel {
+		return makeOpts(assertSlice[OT](opts)...)
+	}
+
+	sshServerOf := func(opts Tunnel) (*ssh.ServerConfig, SSHConnHandler) {
+		withSSHServer, ok := opts.(interface {
+			SSHServer() (*ssh.ServerConfig, SSHConnHandler)
+		})
+		require.True(t, ok, "opts should have the SSHServer method")
+		return withSSHServer.SSHServer()
+	}
+
+	srv, handler := sshServerOf(optsFunc())
+	require.Nil(t, srv)
+	require.Nil(t, handler)
+
+	expected := &ssh.ServerConfig{}
+	called := false
+	srv, handler = sshServerOf(optsFunc(WithSSHServer(expected, func(*ssh.ServerConn, <-chan ssh.NewChannel, <-chan *ssh.Request) {
+		called = true
+	})))
+	require.Equal(t, expected, srv)
+	require.NotNil(t, handler)
+	handler(nil, nil, nil)
+	require.True(t, called)
+}
+
+func TestSSHServer(t *testing.T) {
+	testSSHServer[sshOptions](t, SSHEndpoint)
+	testSSHServer[tcpOptions](t, TCPEndpoint)
+	testSSHServer[labeledOptions](t, LabeledTunnel)
+}
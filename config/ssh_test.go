@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+func TestSSH(t *testing.T) {
+	cases := testCases[sshOptions, proto.SSHOptions]{
+		{
+			name:         "basic",
+			opts:         SSHEndpoint(),
+			expectProto:  stringPtr("ssh"),
+			expectLabels: labelPtr(nil),
+			expectOpts: func(t *testing.T, opts *proto.SSHOptions) {
+				require.Empty(t, opts.Username)
+				require.Empty(t, opts.Password)
+			},
+		},
+		{
+			name: "with credentials",
+			opts: SSHEndpoint(WithSSHCredentials("foo", "bar")),
+			expectOpts: func(t *testing.T, opts *proto.SSHOptions) {
+				require.Equal(t, "foo", opts.Username)
+				require.Equal(t, "bar", opts.Password)
+			},
+		},
+	}
+
+	cases.runAll(t)
+}
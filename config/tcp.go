@@ -3,6 +3,8 @@ package config
 import (
 	"net/http"
 
+	"golang.org/x/crypto/ssh"
+
 	"golang.ngrok.com/ngrok/internal/tunnel/proto"
 )
 
@@ -33,6 +35,8 @@ type tcpOptions struct {
 	RemoteAddr string
 	// An HTTP Server to run traffic on
 	httpServer *http.Server
+	// An SSH server to run traffic on
+	sshServer *sshServerOption
 }
 
 // Set the TCP address to request for this edge.
@@ -74,6 +78,10 @@ func (cfg tcpOptions) HTTPServer() *http.Server {
 	return cfg.httpServer
 }
 
+func (cfg tcpOptions) SSHServer() (*ssh.ServerConfig, SSHConnHandler) {
+	return cfg.sshServer.get()
+}
+
 // compile-time check that we're implementing the proper interfaces.
 var _ interface {
 	tunnelConfigPrivate
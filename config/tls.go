@@ -1,7 +1,11 @@
 package config
 
 import (
+	"context"
+	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"golang.ngrok.com/ngrok/internal/pb"
@@ -42,6 +46,8 @@ type tlsOptions struct {
 
 	// Certificates to use for client authentication at the ngrok edge.
 	MutualTLSCA []*x509.Certificate
+	// CAs to use for client authentication in the library.
+	MutualTLSAtAgent *x509.CertPool
 
 	// True if the TLS connection should be terminated at the ngrok edge.
 	terminateAtEdge bool
@@ -50,18 +56,31 @@ type tlsOptions struct {
 	// The certificate to use for TLS termination at the ngrok edge in PEM
 	// format.
 	CertPEM []byte
+	// If set, the edge termination key pair is resolved from these sources.
+	keySource  SecretSource
+	certSource SecretSource
+	// Set if the TLS connection should be terminated in the library.
+	libraryTermination *tlsTermination
+
+	// If set, secrets are sealed before being sent to the ngrok service.
+	sealer *secretSealer
+
+	// Errors encountered while applying options.
+	err error
 
 	// An HTTP Server to run traffic on
 	httpServer *http.Server
 }
 
-func (cfg *tlsOptions) toProtoConfig() *proto.TLSEndpoint {
+func (cfg *tlsOptions) toProtoConfig() (*proto.TLSEndpoint, error) {
 	opts := &proto.TLSEndpoint{
 		Domain:     cfg.Domain,
 		ProxyProto: proto.ProxyProto(cfg.ProxyProto),
 
 		Subdomain: cfg.Subdomain,
 		Hostname:  cfg.Hostname,
+
+		MutualTLSAtAgent: cfg.MutualTLSAtAgent != nil,
 	}
 
 	opts.IPRestriction = cfg.commonOpts.CIDRRestrictions.toProtoConfig()
@@ -71,13 +90,18 @@ func (cfg *tlsOptions) toProtoConfig() *proto.TLSEndpoint {
 	// When terminate-at-edge is set the TLSTermination must be sent even if the key and cert are nil,
 	// this will default to the ngrok edge's automatically provisioned keypair.
 	if cfg.terminateAtEdge {
+		key, sealedKey, err := cfg.sealer.seal(cfg.KeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("TLS termination key: %w", err)
+		}
 		opts.TLSTermination = &pb.MiddlewareConfiguration_TLSTermination{
-			Key:  cfg.KeyPEM,
-			Cert: cfg.CertPEM,
+			Key:       key,
+			SealedKey: sealedKey,
+			Cert:      cfg.CertPEM,
 		}
 	}
 
-	return opts
+	return opts, nil
 }
 
 func (cfg tlsOptions) tunnelOptions() {}
@@ -93,9 +117,52 @@ func (cfg tlsOptions) Extra() proto.BindExtra {
 func (cfg tlsOptions) Proto() string {
 	return "tls"
 }
+
+// Opts returns the options to bind the tunnel with, or nil if the key can't
+// be sealed. Tunnels are bound with ResolveOpts, which reports why.
 func (cfg tlsOptions) Opts() any {
+	opts, _ := cfg.toProtoConfig()
+	return opts
+}
+
+// ResolveOpts resolves any secret sources and returns the options to bind the
+// tunnel with.
+func (cfg tlsOptions) ResolveOpts(ctx context.Context) (any, error) {
+	var err error
+	if cfg.KeyPEM, err = resolveSecret(ctx, cfg.keySource, cfg.KeyPEM); err != nil {
+		return nil, fmt.Errorf("resolving TLS termination key: %w", err)
+	}
+	if cfg.CertPEM, err = resolveSecret(ctx, cfg.certSource, cfg.CertPEM); err != nil {
+		return nil, fmt.Errorf("resolving TLS termination certificate: %w", err)
+	}
 	return cfg.toProtoConfig()
 }
+
+// HasSecretSources reports whether the TLS termination key pair is resolved
+// from a [SecretSource], and so must be resolved again each time the tunnel
+// is re-bound.
+func (cfg tlsOptions) HasSecretSources() bool {
+	return cfg.keySource != nil || cfg.certSource != nil
+}
+
+// ResolveSecretsInto resolves any secret sources, and returns a copy of opts,
+// the options the tunnel was last bound with, with the TLS termination key
+// pair replaced. Anything the ngrok service assigned, such as the hostname,
+// is kept.
+func (cfg tlsOptions) ResolveSecretsInto(ctx context.Context, opts any) (any, error) {
+	resolved, err := cfg.ResolveOpts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, ok := opts.(*proto.TLSEndpoint)
+	if !ok || current == nil {
+		return resolved, nil
+	}
+	merged := *current
+	merged.TLSTermination = resolved.(*proto.TLSEndpoint).TLSTermination
+	return &merged, nil
+}
+
 func (cfg tlsOptions) Labels() map[string]string {
 	return nil
 }
@@ -103,6 +170,49 @@ func (cfg tlsOptions) HTTPServer() *http.Server {
 	return cfg.httpServer
 }
 
+// Validate reports configuration errors that can be detected before starting
+// the tunnel.
+func (cfg tlsOptions) Validate() error {
+	return cfg.err
+}
+
+// TLSTerminationConfig returns the configuration to use for terminating TLS
+// in the library, or nil if connections should be passed through as-is. Key
+// pair sources are first resolved with ctx.
+func (cfg tlsOptions) TLSTerminationConfig(ctx context.Context) (*tls.Config, error) {
+	if cfg.libraryTermination == nil {
+		if cfg.MutualTLSAtAgent != nil {
+			return nil, errors.New("mutual TLS at the agent requires TLS termination in the library")
+		}
+		return nil, nil
+	}
+
+	tlsCfg, err := cfg.libraryTermination.tlsConfig(ctx, cfg.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MutualTLSAtAgent != nil {
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsCfg.ClientCAs = cfg.MutualTLSAtAgent
+		if cfg.libraryTermination.acme != nil {
+			// the ACME server never presents a client certificate when it
+			// validates a TLS-ALPN-01 challenge
+			challengeCfg := tlsCfg.Clone()
+			challengeCfg.ClientAuth = tls.NoClientCert
+			challengeCfg.ClientCAs = nil
+			tlsCfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				if isChallengeHello(hello) {
+					return challengeCfg, nil
+				}
+				return nil, nil
+			}
+		}
+	}
+
+	return tlsCfg, nil
+}
+
 // compile-time check that we're implementing the proper interfaces.
 var _ interface {
 	tunnelConfigPrivate
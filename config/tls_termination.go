@@ -1,5 +1,22 @@
 package config
 
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// How long a key pair resolved from sources is served before it's
+	// resolved again.
+	keyPairRefreshInterval = time.Minute
+	// How long resolving a key pair may hold up a handshake.
+	keyPairResolveTimeout = 30 * time.Second
+)
+
 type TLSTerminationLocation int
 
 const (
@@ -9,31 +26,130 @@ const (
 	// Terminate TLS in the ngrok library. The library will receive the
 	// handshake and perform TLS termination, and the backend will receive the
 	// plaintext stream.
-	// TODO: export this once implmented
-	tlsAtLibrary
+	TLSAtLibrary
 )
 
 type tlsTermination struct {
 	location TLSTerminationLocation
 	key      []byte
 	cert     []byte
+	acme     *acmeOptions
+
+	// If set, the key pair is resolved from these sources instead.
+	keySource  SecretSource
+	certSource SecretSource
 }
 
 func (tt tlsTermination) ApplyTLS(cfg *tlsOptions) {
 	switch tt.location {
-	case tlsAtLibrary:
+	case TLSAtLibrary:
+		cfg.terminateAtEdge = false
 		cfg.KeyPEM = nil
 		cfg.CertPEM = nil
-		// TODO: implement this in the tunnel `Accept` call.
-		panic("automatic tls termination in-app is not yet supported")
+		cfg.keySource = nil
+		cfg.certSource = nil
+		cfg.libraryTermination = &tt
 	case TLSAtEdge:
 		cfg.terminateAtEdge = true
 		cfg.KeyPEM = tt.key
 		cfg.CertPEM = tt.cert
+		cfg.keySource = tt.keySource
+		cfg.certSource = tt.certSource
+		cfg.libraryTermination = nil
 		return
 	}
 }
 
+// The tls.Config used to terminate TLS in the library for the given domain.
+// A key pair from sources is resolved now, so that problems with it are
+// reported when the tunnel starts, and again as handshakes arrive.
+func (tt *tlsTermination) tlsConfig(ctx context.Context, domain string) (*tls.Config, error) {
+	if tt.acme != nil {
+		return tt.acme.tlsConfig(domain)
+	}
+
+	cert, err := tt.keyPair(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if tt.certSource == nil && tt.keySource == nil {
+		tlsCfg.Certificates = []tls.Certificate{*cert}
+	} else {
+		loader := &keyPairLoader{tt: tt, cert: cert, loaded: time.Now()}
+		tlsCfg.GetCertificate = loader.getCertificate
+	}
+	return tlsCfg, nil
+}
+
+// Resolves the key pair to terminate TLS with.
+func (tt *tlsTermination) keyPair(ctx context.Context) (*tls.Certificate, error) {
+	certPEM, err := resolveSecret(ctx, tt.certSource, tt.cert)
+	if err != nil {
+		return nil, fmt.Errorf("resolving TLS termination certificate: %w", err)
+	}
+	keyPEM, err := resolveSecret(ctx, tt.keySource, tt.key)
+	if err != nil {
+		return nil, fmt.Errorf("resolving TLS termination key: %w", err)
+	}
+
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil, errors.New("TLS termination in the library requires a key pair or ACME")
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// Serves a key pair resolved from sources, so that rotated keys are picked
+// up without restarting the tunnel.
+type keyPairLoader struct {
+	tt *tlsTermination
+
+	mu         sync.Mutex
+	cert       *tls.Certificate
+	loaded     time.Time
+	refreshing bool
+}
+
+// Returns the most recently resolved key pair. Once it's older than
+// keyPairRefreshInterval, one handshake resolves it again while the others
+// carry on with the current one. If resolving fails, the current key pair is
+// kept until the next attempt.
+func (l *keyPairLoader) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.Lock()
+	cert := l.cert
+	if l.refreshing || time.Since(l.loaded) < keyPairRefreshInterval {
+		l.mu.Unlock()
+		return cert, nil
+	}
+	l.refreshing = true
+	l.mu.Unlock()
+
+	ctx := hello.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, keyPairResolveTimeout)
+	defer cancel()
+	fresh, err := l.tt.keyPair(ctx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refreshing = false
+	l.loaded = time.Now()
+	if err == nil {
+		l.cert = fresh
+	}
+	return l.cert, nil
+}
+
 type TLSTerminationOption func(tt *tlsTermination)
 
 // WithTLSTermination arranges for incoming TLS connections to be automatically terminated.
@@ -64,7 +180,8 @@ func WithTermination(certPEM, keyPEM []byte) TLSEndpointOption {
 }
 
 // WithTLSTerminationAt determines where TLS termination should occur.
-// Currently, only `TLSAtEdge` is supported.
+// Terminating at the library requires either [WithTLSTerminationKeyPair] or
+// [WithTLSTerminationACME].
 func WithTLSTerminationAt(location TLSTerminationLocation) TLSTerminationOption {
 	return TLSTerminationOption(func(cfg *tlsTermination) {
 		cfg.location = location
@@ -74,10 +191,23 @@ func WithTLSTerminationAt(location TLSTerminationLocation) TLSTerminationOption
 // WithTLSTerminationKeyPair sets a custom key and certificate in PEM format for
 // TLS termination.
 // If terminating at the ngrok edge, this uploads the private key and
-// certificate to the ngrok servers.
+// certificate to the ngrok servers. If terminating in the library, they never
+// leave the process.
 func WithTLSTerminationKeyPair(certPEM, keyPEM []byte) TLSTerminationOption {
 	return TLSTerminationOption(func(cfg *tlsTermination) {
 		cfg.cert = certPEM
 		cfg.key = keyPEM
 	})
 }
+
+// WithTLSTerminationKeyPairSource is like [WithTLSTerminationKeyPair], but
+// resolves the certificate and key from the given sources. When terminating at
+// the ngrok edge they are resolved each time the tunnel is bound. When
+// terminating in the library they are resolved when the tunnel is started,
+// and again at most once a minute as connections arrive.
+func WithTLSTerminationKeyPairSource(certPEM, keyPEM SecretSource) TLSTerminationOption {
+	return TLSTerminationOption(func(cfg *tlsTermination) {
+		cfg.certSource = certPEM
+		cfg.keySource = keyPEM
+	})
+}
@@ -1,6 +1,11 @@
 package config
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -61,3 +66,127 @@ func TestTLSTermination(t *testing.T) {
 
 	cases.runAll(t)
 }
+
+func testKeyPair(t *testing.T, domain string) (certPEM, keyPEM []byte) {
+	cert, err := selfSignedCertificate(domain)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return
+}
+
+func TestTLSTerminationAtLibrary(t *testing.T) {
+	certPEM, keyPEM := testKeyPair(t, "example.com")
+
+	cases := testCases[tlsOptions, proto.TLSEndpoint]{
+		{
+			name: "with key pair",
+			opts: TLSEndpoint(WithTLSTermination(
+				WithTLSTerminationAt(TLSAtLibrary),
+				WithTLSTerminationKeyPair(certPEM, keyPEM),
+			)),
+			expectOpts: func(t *testing.T, opts *proto.TLSEndpoint) {
+				require.Nil(t, opts.TLSTermination)
+			},
+		},
+		{
+			name: "with acme",
+			opts: TLSEndpoint(
+				WithDomain("example.com"),
+				WithTLSTermination(WithTLSTerminationACME()),
+			),
+			expectOpts: func(t *testing.T, opts *proto.TLSEndpoint) {
+				require.Nil(t, opts.TLSTermination)
+			},
+		},
+	}
+
+	cases.runAll(t)
+
+	tlsCfg, err := TLSEndpoint(WithTLSTermination(
+		WithTLSTerminationAt(TLSAtLibrary),
+		WithTLSTerminationKeyPair(certPEM, keyPEM),
+	)).(tlsOptions).TLSTerminationConfig(context.Background())
+	require.NoError(t, err)
+	require.Len(t, tlsCfg.Certificates, 1)
+
+	tlsCfg, err = TLSEndpoint(WithTLSTermination()).(tlsOptions).TLSTerminationConfig(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, tlsCfg)
+
+	_, err = TLSEndpoint(WithTLSTermination(
+		WithTLSTerminationAt(TLSAtLibrary),
+	)).(tlsOptions).TLSTerminationConfig(context.Background())
+	require.Error(t, err)
+}
+
+func TestTLSTerminationACME(t *testing.T) {
+	_, err := TLSEndpoint(WithTLSTermination(WithTLSTerminationACME())).(tlsOptions).TLSTerminationConfig(context.Background())
+	require.Error(t, err, "ACME requires a domain")
+
+	tlsCfg, err := TLSEndpoint(
+		WithDomain("example.com"),
+		WithTLSTermination(WithTLSTerminationACME(
+			WithACMEDirectoryURL("http://127.0.0.1:1/directory"),
+			WithACMEDevMode(),
+		)),
+	).(tlsOptions).TLSTerminationConfig(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, tlsCfg.NextProtos, "acme-tls/1")
+
+	cert, err := tlsCfg.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"example.com"}, cert.Leaf.DNSNames)
+	require.Equal(t, cert.Leaf.Issuer, cert.Leaf.Subject, "dev mode should fall back to a self-signed certificate")
+}
+
+func TestTLSTerminationACMEDevModeChallenge(t *testing.T) {
+	tlsCfg, err := TLSEndpoint(
+		WithDomain("example.com"),
+		WithTLSTermination(WithTLSTerminationACME(
+			WithACMEDirectoryURL("http://127.0.0.1:1/directory"),
+			WithACMEDevMode(),
+		)),
+	).(tlsOptions).TLSTerminationConfig(context.Background())
+	require.NoError(t, err)
+
+	// challenges fail rather than being answered with the self-signed
+	// certificate
+	cert, err := tlsCfg.GetCertificate(&tls.ClientHelloInfo{
+		ServerName:      "example.com",
+		SupportedProtos: []string{"acme-tls/1"},
+	})
+	require.Error(t, err)
+	require.Nil(t, cert)
+}
+
+func TestTLSTerminationACMEMutualTLS(t *testing.T) {
+	pool := x509.NewCertPool()
+	tlsCfg, err := TLSEndpoint(
+		WithDomain("example.com"),
+		WithMutualTLSAtAgent(pool),
+		WithTLSTermination(WithTLSTerminationACME(
+			WithACMEDirectoryURL("http://127.0.0.1:1/directory"),
+		)),
+	).(tlsOptions).TLSTerminationConfig(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, tlsCfg.ClientAuth)
+	require.NotNil(t, tlsCfg.GetConfigForClient)
+
+	// ordinary clients must present a certificate
+	override, err := tlsCfg.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "example.com"})
+	require.NoError(t, err)
+	require.Nil(t, override)
+
+	// the ACME server validating a challenge doesn't
+	override, err = tlsCfg.GetConfigForClient(&tls.ClientHelloInfo{
+		ServerName:      "example.com",
+		SupportedProtos: []string{"acme-tls/1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, tls.NoClientCert, override.ClientAuth)
+	require.Contains(t, override.NextProtos, "acme-tls/1")
+	require.NotNil(t, override.GetCertificate)
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"context"
+	"fmt"
+
 	"golang.ngrok.com/ngrok/internal/pb"
 	"golang.ngrok.com/ngrok/internal/tunnel/proto"
 )
@@ -11,16 +14,36 @@ type webhookVerification struct {
 	Provider string
 	// The secret for verifying webhooks from this provider.
 	Secret proto.ObfuscatedString
+	// If set, the secret is resolved from this source instead.
+	SecretSource SecretSource
 }
 
-func (wv *webhookVerification) toProtoConfig() *pb.MiddlewareConfiguration_WebhookVerification {
+func (wv *webhookVerification) resolveSecrets(ctx context.Context) (*webhookVerification, error) {
+	if wv == nil || wv.SecretSource == nil {
+		return wv, nil
+	}
+	secret, err := wv.SecretSource.Secret(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolving webhook verification secret: %w", err)
+	}
+	resolved := *wv
+	resolved.Secret = proto.ObfuscatedString(secret)
+	return &resolved, nil
+}
+
+func (wv *webhookVerification) toProtoConfig(sealer *secretSealer) (*pb.MiddlewareConfiguration_WebhookVerification, error) {
 	if wv == nil {
-		return nil
+		return nil, nil
 	}
-	return &pb.MiddlewareConfiguration_WebhookVerification{
-		Provider: wv.Provider,
-		Secret:   wv.Secret.PlainText(),
+	secret, sealedSecret, err := sealer.sealString(wv.Secret.PlainText())
+	if err != nil {
+		return nil, err
 	}
+	return &pb.MiddlewareConfiguration_WebhookVerification{
+		Provider:     wv.Provider,
+		Secret:       secret,
+		SealedSecret: sealedSecret,
+	}, nil
 }
 
 // WithWebhookVerification configures webhook vericiation for this edge.
@@ -32,3 +55,14 @@ func WithWebhookVerification(provider string, secret string) HTTPEndpointOption
 		}
 	})
 }
+
+// WithWebhookVerificationSource configures webhook verification for this edge
+// with a secret that is resolved from src each time the tunnel is bound.
+func WithWebhookVerificationSource(provider string, src SecretSource) HTTPEndpointOption {
+	return httpOptionFunc(func(cfg *httpOptions) {
+		cfg.WebhookVerification = &webhookVerification{
+			Provider:     provider,
+			SecretSource: src,
+		}
+	})
+}
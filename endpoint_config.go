@@ -0,0 +1,239 @@
+package ngrok
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.ngrok.com/ngrok/config"
+	"golang.ngrok.com/ngrok/internal/pb"
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+// EndpointConfig is the configuration that the ngrok service applied to a
+// tunnel's endpoint when it was bound. It is one of [*HTTPEndpointConfig],
+// [*TCPEndpointConfig], [*TLSEndpointConfig], or [*SSHEndpointConfig].
+type EndpointConfig interface {
+	endpointConfig()
+}
+
+// IPRestriction is the effective set of CIDRs allowed or denied access to an
+// endpoint.
+type IPRestriction struct {
+	AllowCIDRs []string
+	DenyCIDRs  []string
+}
+
+// HeaderRules are the header modifications applied to requests or responses
+// by an HTTP endpoint.
+type HeaderRules struct {
+	Add    map[string]string
+	Remove []string
+}
+
+// HTTPEndpointConfig is the configuration applied to an HTTP endpoint.
+type HTTPEndpointConfig struct {
+	// The scheme of the endpoint, either "http" or "https".
+	Scheme string
+	// The public hostname assigned to the endpoint.
+	Hostname string
+	// The domain that was requested, if any.
+	Domain string
+	// The subdomain that was requested, if any.
+	Subdomain string
+	// The PROXY protocol version sent to the backend.
+	ProxyProto config.ProxyProtoVersion
+
+	// True if responses are gzip-compressed.
+	Compression bool
+	// The error threshold of the circuit breaker, or 0 if there is none.
+	CircuitBreaker float64
+	// The IP restrictions on the endpoint, if any.
+	IPRestriction *IPRestriction
+	// The usernames allowed by basic authentication, if enabled.
+	BasicAuthUsers []string
+	// The OAuth provider users must authenticate with, if any.
+	OAuthProvider string
+	// The issuer of the OIDC provider users must authenticate with, if any.
+	OIDCIssuerURL string
+	// The provider webhooks are verified for, if any.
+	WebhookVerificationProvider string
+	// True if clients must present a certificate signed by a configured CA.
+	MutualTLS bool
+	// Modifications made to request and response headers, if any.
+	RequestHeaders  *HeaderRules
+	ResponseHeaders *HeaderRules
+	// True if websocket connections are converted to TCP streams.
+	WebsocketTCPConversion bool
+}
+
+// TCPEndpointConfig is the configuration applied to a TCP endpoint.
+type TCPEndpointConfig struct {
+	// The public host and port assigned to the endpoint.
+	Host string
+	Port int
+	// The PROXY protocol version sent to the backend.
+	ProxyProto config.ProxyProtoVersion
+	// The IP restrictions on the endpoint, if any.
+	IPRestriction *IPRestriction
+}
+
+// TLSEndpointConfig is the configuration applied to a TLS endpoint.
+type TLSEndpointConfig struct {
+	// The public hostname assigned to the endpoint.
+	Hostname string
+	// The domain that was requested, if any.
+	Domain string
+	// The subdomain that was requested, if any.
+	Subdomain string
+	// The PROXY protocol version sent to the backend.
+	ProxyProto config.ProxyProtoVersion
+
+	// True if TLS is terminated at the ngrok edge.
+	TerminateAtEdge bool
+	// True if clients must present a certificate signed by a CA configured
+	// at the ngrok edge.
+	MutualTLSAtEdge bool
+	// True if client certificates are verified in the library.
+	MutualTLSAtAgent bool
+	// The IP restrictions on the endpoint, if any.
+	IPRestriction *IPRestriction
+}
+
+// SSHEndpointConfig is the configuration applied to an SSH endpoint.
+type SSHEndpointConfig struct {
+	// The public hostname assigned to the endpoint.
+	Hostname string
+	// The username SSH clients authenticate with, if any.
+	Username string
+	// The PROXY protocol version sent to the backend.
+	ProxyProto config.ProxyProtoVersion
+}
+
+func (*HTTPEndpointConfig) endpointConfig() {}
+func (*TCPEndpointConfig) endpointConfig()  {}
+func (*TLSEndpointConfig) endpointConfig()  {}
+func (*SSHEndpointConfig) endpointConfig()  {}
+
+// Builds the public view of the options returned by the server when binding.
+// Returns nil for labeled tunnels and unrecognized options.
+func newEndpointConfig(protocol, tunnelURL string, opts any) EndpointConfig {
+	u, _ := url.Parse(tunnelURL)
+	hostname := ""
+	if u != nil {
+		hostname = u.Hostname()
+	}
+
+	switch opts := opts.(type) {
+	case *proto.HTTPEndpoint:
+		cfg := &HTTPEndpointConfig{
+			Scheme:    protocol,
+			Hostname:  opts.Hostname,
+			Domain:    opts.Domain,
+			Subdomain: opts.Subdomain,
+
+			ProxyProto: config.ProxyProtoVersion(opts.ProxyProto),
+
+			Compression:            opts.Compression != nil,
+			IPRestriction:          newIPRestriction(opts.IPRestriction),
+			MutualTLS:              opts.MutualTLSCA != nil,
+			RequestHeaders:         newHeaderRules(opts.RequestHeaders),
+			ResponseHeaders:        newHeaderRules(opts.ResponseHeaders),
+			WebsocketTCPConversion: opts.WebsocketTCPConverter != nil,
+		}
+		if cfg.Hostname == "" {
+			cfg.Hostname = hostname
+		}
+		if opts.CircuitBreaker != nil {
+			cfg.CircuitBreaker = opts.CircuitBreaker.ErrorThreshold
+		}
+		if opts.BasicAuth != nil {
+			for _, cred := range opts.BasicAuth.Credentials {
+				cfg.BasicAuthUsers = append(cfg.BasicAuthUsers, cred.Username)
+			}
+		}
+		if opts.OAuth != nil {
+			cfg.OAuthProvider = opts.OAuth.Provider
+		}
+		if opts.OIDC != nil {
+			cfg.OIDCIssuerURL = opts.OIDC.IssuerUrl
+		}
+		if opts.WebhookVerification != nil {
+			cfg.WebhookVerificationProvider = opts.WebhookVerification.Provider
+		}
+		return cfg
+	case *proto.TCPEndpoint:
+		cfg := &TCPEndpointConfig{
+			ProxyProto:    config.ProxyProtoVersion(opts.ProxyProto),
+			IPRestriction: newIPRestriction(opts.IPRestriction),
+		}
+		addr := opts.Addr
+		if u != nil && u.Host != "" {
+			addr = u.Host
+		}
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			cfg.Host = host
+			cfg.Port, _ = strconv.Atoi(port)
+		} else {
+			cfg.Host = addr
+		}
+		return cfg
+	case *proto.TLSEndpoint:
+		cfg := &TLSEndpointConfig{
+			Hostname:  opts.Hostname,
+			Domain:    opts.Domain,
+			Subdomain: opts.Subdomain,
+
+			ProxyProto: config.ProxyProtoVersion(opts.ProxyProto),
+
+			TerminateAtEdge:  opts.TLSTermination != nil,
+			MutualTLSAtEdge:  opts.MutualTLSAtEdge != nil,
+			MutualTLSAtAgent: opts.MutualTLSAtAgent,
+			IPRestriction:    newIPRestriction(opts.IPRestriction),
+		}
+		if cfg.Hostname == "" {
+			cfg.Hostname = hostname
+		}
+		return cfg
+	case *proto.SSHOptions:
+		cfg := &SSHEndpointConfig{
+			Hostname:   opts.Hostname,
+			Username:   opts.Username,
+			ProxyProto: config.ProxyProtoVersion(opts.ProxyProto),
+		}
+		if cfg.Hostname == "" {
+			cfg.Hostname = hostname
+		}
+		return cfg
+	}
+	return nil
+}
+
+func newIPRestriction(r *pb.MiddlewareConfiguration_IPRestriction) *IPRestriction {
+	if r == nil {
+		return nil
+	}
+	return &IPRestriction{
+		AllowCIDRs: r.AllowCidrs,
+		DenyCIDRs:  r.DenyCidrs,
+	}
+}
+
+func newHeaderRules(h *pb.MiddlewareConfiguration_Headers) *HeaderRules {
+	if h == nil {
+		return nil
+	}
+	rules := &HeaderRules{
+		Add:    h.AddParsed,
+		Remove: h.Remove,
+	}
+	if rules.Add == nil && len(h.Add) > 0 {
+		rules.Add = make(map[string]string, len(h.Add))
+		for _, header := range h.Add {
+			k, v, _ := strings.Cut(header, ":")
+			rules.Add[k] = v
+		}
+	}
+	return rules
+}
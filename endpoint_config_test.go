@@ -0,0 +1,65 @@
+package ngrok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok/config"
+	"golang.ngrok.com/ngrok/internal/pb"
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+func TestEndpointConfig(t *testing.T) {
+	cfg := newEndpointConfig("https", "https://example.ngrok.io", &proto.HTTPEndpoint{
+		Domain:         "example.ngrok.io",
+		ProxyProto:     proto.ProxyProto(config.ProxyProtoV2),
+		Compression:    &pb.MiddlewareConfiguration_Compression{},
+		CircuitBreaker: &pb.MiddlewareConfiguration_CircuitBreaker{ErrorThreshold: 0.5},
+		BasicAuth: &pb.MiddlewareConfiguration_BasicAuth{
+			Credentials: []*pb.MiddlewareConfiguration_BasicAuthCredential{{Username: "user"}},
+		},
+		OAuth:          &pb.MiddlewareConfiguration_OAuth{Provider: "google"},
+		RequestHeaders: &pb.MiddlewareConfiguration_Headers{Add: []string{"x-foo:bar"}},
+	})
+	http, ok := cfg.(*HTTPEndpointConfig)
+	require.True(t, ok)
+	require.Equal(t, "https", http.Scheme)
+	require.Equal(t, "example.ngrok.io", http.Hostname)
+	require.Equal(t, config.ProxyProtoV2, http.ProxyProto)
+	require.True(t, http.Compression)
+	require.Equal(t, 0.5, http.CircuitBreaker)
+	require.Equal(t, []string{"user"}, http.BasicAuthUsers)
+	require.Equal(t, "google", http.OAuthProvider)
+	require.Equal(t, map[string]string{"x-foo": "bar"}, http.RequestHeaders.Add)
+	require.Nil(t, http.ResponseHeaders)
+	require.Nil(t, http.IPRestriction)
+
+	cfg = newEndpointConfig("tcp", "tcp://1.tcp.ngrok.io:12345", &proto.TCPEndpoint{
+		IPRestriction: &pb.MiddlewareConfiguration_IPRestriction{AllowCidrs: []string{"10.0.0.0/8"}},
+	})
+	tcp, ok := cfg.(*TCPEndpointConfig)
+	require.True(t, ok)
+	require.Equal(t, "1.tcp.ngrok.io", tcp.Host)
+	require.Equal(t, 12345, tcp.Port)
+	require.Equal(t, []string{"10.0.0.0/8"}, tcp.IPRestriction.AllowCIDRs)
+
+	cfg = newEndpointConfig("tls", "tls://example.ngrok.io", &proto.TLSEndpoint{
+		TLSTermination:   &pb.MiddlewareConfiguration_TLSTermination{},
+		MutualTLSAtAgent: true,
+	})
+	tls, ok := cfg.(*TLSEndpointConfig)
+	require.True(t, ok)
+	require.Equal(t, "example.ngrok.io", tls.Hostname)
+	require.True(t, tls.TerminateAtEdge)
+	require.True(t, tls.MutualTLSAtAgent)
+	require.False(t, tls.MutualTLSAtEdge)
+
+	cfg = newEndpointConfig("ssh", "ssh://example.ngrok.io", &proto.SSHOptions{Username: "user"})
+	ssh, ok := cfg.(*SSHEndpointConfig)
+	require.True(t, ok)
+	require.Equal(t, "example.ngrok.io", ssh.Hostname)
+	require.Equal(t, "user", ssh.Username)
+
+	require.Nil(t, newEndpointConfig("", "", nil))
+}
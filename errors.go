@@ -1,8 +1,10 @@
 package ngrok
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/url"
+	"regexp"
 )
 
 // Errors arising from authentication failure.
@@ -12,6 +14,9 @@ type errAuthFailed struct {
 	Remote bool
 	// The underlying error.
 	Inner error
+	// Whether the authtoken came from an [AuthtokenProvider], which may
+	// supply a different one next time.
+	Provided bool
 }
 
 func (e errAuthFailed) Error() string {
@@ -30,10 +35,37 @@ func (e errAuthFailed) Unwrap() error {
 }
 
 func (e errAuthFailed) Is(target error) bool {
+	if target == ErrAuthFailed {
+		return e.Remote
+	}
 	_, ok := target.(errAuthFailed)
 	return ok
 }
 
+// Permanent reports whether the ngrok service rejected the authtoken itself,
+// so that retrying with it can't succeed. The session gives up reconnecting
+// on such errors. A rejected authtoken from a provider isn't permanent, since
+// the provider may have a valid one by the next attempt.
+func (e errAuthFailed) Permanent() bool {
+	return e.Remote && !e.Provided && e.Inner != nil && authtokenRejected(e.Inner.Error())
+}
+
+// The error codes the ngrok service uses when it rejects an authtoken: it's
+// malformed, it's an API key, or it's invalid or revoked.
+var authtokenRejectedCodes = map[string]bool{
+	"ERR_NGROK_105": true,
+	"ERR_NGROK_106": true,
+	"ERR_NGROK_107": true,
+}
+
+var errorCodePattern = regexp.MustCompile(`ERR_NGROK_\d+`)
+
+// Reports whether msg, an error from the ngrok service, rejects an
+// authtoken.
+func authtokenRejected(msg string) bool {
+	return authtokenRejectedCodes[errorCodePattern.FindString(msg)]
+}
+
 // The error returned by [Tunnel]'s [net.Listener.Accept] method.
 type errAcceptFailed struct {
 	// The underlying error.
@@ -113,3 +145,57 @@ func (e errSessionDial) Is(target error) bool {
 	_, ok := target.(errSessionDial)
 	return ok
 }
+
+// Error arising from a failure to query the ngrok server for its information.
+type errServerInfo struct {
+	// The underlying error.
+	Inner error
+}
+
+func (e errServerInfo) Error() string {
+	return fmt.Sprintf("failed to get server info: %v", e.Inner)
+}
+
+func (e errServerInfo) Unwrap() error {
+	return e.Inner
+}
+
+func (e errServerInfo) Is(target error) bool {
+	_, ok := target.(errServerInfo)
+	return ok
+}
+
+// Error arising from a failure to update session or tunnel metadata.
+type errMetadataUpdate struct {
+	// The underlying error.
+	Inner error
+}
+
+func (e errMetadataUpdate) Error() string {
+	return fmt.Sprintf("failed to update metadata: %v", e.Inner)
+}
+
+func (e errMetadataUpdate) Unwrap() error {
+	return e.Inner
+}
+
+func (e errMetadataUpdate) Is(target error) bool {
+	_, ok := target.(errMetadataUpdate)
+	return ok
+}
+
+// Error arising from the ngrok server presenting a certificate that doesn't
+// match any of the pins set by [WithServerPin].
+type errServerPinMismatch struct {
+	// The SPKI SHA-256 pin of the server's leaf certificate.
+	Pin []byte
+}
+
+func (e errServerPinMismatch) Error() string {
+	return fmt.Sprintf("server certificate does not match any pinned key, leaf key pin is %s", base64.StdEncoding.EncodeToString(e.Pin))
+}
+
+func (e errServerPinMismatch) Is(target error) bool {
+	_, ok := target.(errServerPinMismatch)
+	return ok
+}
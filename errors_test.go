@@ -12,7 +12,7 @@ var testError = errors.New("testing, 1 2 3!")
 // Sanity check for the appraoch to error construction/wrapping
 func TestErrorWrapping(t *testing.T) {
 	var accept error = errAcceptFailed{Inner: testError}
-	var auth error = errAuthFailed{true, accept}
+	var auth error = errAuthFailed{true, accept, false}
 
 	require.True(t, errors.Is(accept, errAcceptFailed{}))
 	require.True(t, errors.Is(auth, errAuthFailed{}))
@@ -8,6 +8,7 @@ require (
 	github.com/stretchr/testify v1.8.0
 	go.uber.org/multierr v1.10.0
 	golang.ngrok.com/muxado/v2 v2.0.0
+	golang.org/x/crypto v0.9.0
 	golang.org/x/net v0.10.0
 	google.golang.org/protobuf v1.28.1
 )
@@ -23,6 +24,7 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
 	golang.org/x/term v0.8.0 // indirect
+	golang.org/x/text v0.9.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,111 @@
+package ngrok
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+// How long a dial to a cached ingress address may take before falling back
+// to resolving the server address again.
+const cachedIngressDialTimeout = 5 * time.Second
+
+// WithTLSClientSessionCache sets the cache of TLS sessions used to resume
+// earlier connections to the ngrok service, which skips most of the TLS
+// handshake when reconnecting. By default each session keeps its own cache
+// across reconnects; use this to share one between sessions, such as the
+// members of a [SessionPool]. To disable resumption, clear
+// [tls.Config.ClientSessionCache] with [WithTLSConfig].
+func WithTLSClientSessionCache(cache tls.ClientSessionCache) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.ClientSessionCache = cache
+	}
+}
+
+// WithIngressAddrCache remembers the IP address of the ngrok server the
+// session last connected to, and dials it directly when reconnecting rather
+// than resolving the server address again. The address is resolved afresh
+// once ttl has passed since it was cached, or as soon as it stops accepting
+// connections.
+//
+// It has no effect with [WithDialer], [WithProxyURL], or
+// [WithSessionTransport], since the address they connect to isn't the
+// server's.
+func WithIngressAddrCache(ttl time.Duration) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.IngressAddrTTL = ttl
+	}
+}
+
+// The last good address of the ngrok server. A nil cache resolves the server
+// address on every dial.
+type ingressCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	addr    string
+	expires time.Time
+}
+
+// Returns the cache of ingress addresses for cfg, or nil if the session
+// shouldn't use one.
+func (cfg *connectConfig) ingressCache() *ingressCache {
+	if cfg.IngressAddrTTL <= 0 || cfg.Dialer != nil || cfg.ProxyURL != nil || cfg.Transport != nil {
+		return nil
+	}
+	return &ingressCache{ttl: cfg.IngressAddrTTL}
+}
+
+// Dials the cached address if there is one, falling back to addr.
+func (c *ingressCache) dial(ctx context.Context, dialer Dialer, addr string) (net.Conn, error) {
+	if cached := c.get(); cached != "" {
+		cachedCtx, cancel := context.WithTimeout(ctx, cachedIngressDialTimeout)
+		conn, err := dialer.DialContext(cachedCtx, "tcp", cached)
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		c.forget()
+	}
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+func (c *ingressCache) get() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().After(c.expires) {
+		c.addr = ""
+	}
+	return c.addr
+}
+
+// Records the address of a connection to the ngrok server, unless one is
+// already cached. The entry expires ttl after it was first resolved, however
+// often it's used.
+func (c *ingressCache) remember(addr net.Addr) {
+	if c == nil || addr == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.addr != "" && time.Now().Before(c.expires) {
+		return
+	}
+	c.addr = addr.String()
+	c.expires = time.Now().Add(c.ttl)
+}
+
+// Discards the cached address, so that the next dial resolves it again.
+func (c *ingressCache) forget() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addr = ""
+}
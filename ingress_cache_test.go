@@ -0,0 +1,137 @@
+package ngrok
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A Dialer which records the addresses it dials, failing those in down.
+type recordingDialer struct {
+	mu     sync.Mutex
+	dialed []string
+	down   map[string]bool
+}
+
+func (d *recordingDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d *recordingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dialed = append(d.dialed, address)
+	if d.down[address] {
+		return nil, errors.New("unreachable")
+	}
+	client, server := net.Pipe()
+	_ = server.Close()
+	return ingressConn{client}, nil
+}
+
+// A connection to the ingress address the server resolves to.
+type ingressConn struct {
+	net.Conn
+}
+
+func (c ingressConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443}
+}
+
+func TestIngressCache(t *testing.T) {
+	ctx := context.Background()
+	dialer := &recordingDialer{down: map[string]bool{}}
+	cache := (&connectConfig{IngressAddrTTL: time.Hour}).ingressCache()
+
+	conn, err := cache.dial(ctx, dialer, "connect.example.com:443")
+	require.NoError(t, err)
+	cache.remember(conn.RemoteAddr())
+
+	// reconnects skip resolving the server address
+	_, err = cache.dial(ctx, dialer, "connect.example.com:443")
+	require.NoError(t, err)
+	require.Equal(t, []string{"connect.example.com:443", "192.0.2.1:443"}, dialer.dialed)
+
+	// falls back to the server address once the cached one stops working
+	dialer.down["192.0.2.1:443"] = true
+	dialer.dialed = nil
+	_, err = cache.dial(ctx, dialer, "connect.example.com:443")
+	require.NoError(t, err)
+	require.Equal(t, []string{"192.0.2.1:443", "connect.example.com:443"}, dialer.dialed)
+	require.Empty(t, cache.get())
+}
+
+func TestIngressCacheExpiry(t *testing.T) {
+	cache := (&connectConfig{IngressAddrTTL: time.Hour}).ingressCache()
+	first := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443}
+	cache.remember(first)
+	cache.remember(&net.TCPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 443})
+	require.Equal(t, first.String(), cache.get(), "must keep the address until it expires")
+
+	cache.expires = time.Now().Add(-time.Second)
+	require.Empty(t, cache.get())
+}
+
+func TestIngressCacheDisabled(t *testing.T) {
+	require.Nil(t, (&connectConfig{}).ingressCache())
+	require.Nil(t, (&connectConfig{IngressAddrTTL: time.Hour, Dialer: &net.Dialer{}}).ingressCache())
+
+	// a nil cache always dials the server address
+	var cache *ingressCache
+	dialer := &recordingDialer{}
+	_, err := cache.dial(context.Background(), dialer, "connect.example.com:443")
+	require.NoError(t, err)
+	cache.remember(&net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443})
+	_, err = cache.dial(context.Background(), dialer, "connect.example.com:443")
+	require.NoError(t, err)
+	require.Equal(t, []string{"connect.example.com:443", "connect.example.com:443"}, dialer.dialed)
+}
+
+func TestTLSClientSessionCache(t *testing.T) {
+	cert := selfSignedCert(t)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// the client receives its session ticket along with the data
+			_, _ = conn.Write([]byte{1})
+			conn.Close()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	cache := tls.NewLRUClientSessionCache(0)
+
+	connect := func() tls.ConnectionState {
+		settings, _, err := testDialTLS(t,
+			WithServer("localhost:443"),
+			WithCA(roots),
+			WithTLSClientSessionCache(cache),
+		)
+		require.NoError(t, err)
+
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		tlsConn := tls.Client(conn, settings.config)
+		defer tlsConn.Close()
+		_, err = tlsConn.Read(make([]byte, 1))
+		require.NoError(t, err)
+		return tlsConn.ConnectionState()
+	}
+
+	require.False(t, connect().DidResume)
+	require.True(t, connect().DidResume)
+}
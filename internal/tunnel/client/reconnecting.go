@@ -2,6 +2,7 @@ package client
 
 import (
 	"errors"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -101,10 +102,27 @@ type reconnectingSession struct {
 	cb           ReconnectCallback
 	swapper      *swapRaw
 	*session
+
+	// held while connecting or migrating to a new raw session
+	connectMu sync.Mutex
+
+	// raw sessions replaced by Migrate that are still draining
+	retiredMu sync.Mutex
+	retired   map[RawSession]struct{}
+
+	// closed by Close, to cut short the wait between reconnect attempts
+	closing   chan struct{}
+	closeOnce sync.Once
 }
 
 type RawSessionDialer func() (RawSession, error)
-type ReconnectCallback func(s Session) error
+
+// ReconnectCallback authenticates a newly connected session. On success it
+// returns a function to call once the session's tunnels have been re-bound,
+// which records anything describing the new connection. It isn't called if
+// the new connection is abandoned, so nothing is left describing a
+// connection that was never used.
+type ReconnectCallback func(s Session) (commit func(), err error)
 
 // Establish a Session that reconnects across temporary network failures. The
 // returned Session object uses the given dialer to reconnect whenever Accept
@@ -131,18 +149,23 @@ func NewReconnectingSession(logger log.Logger, dialer RawSessionDialer, stateCha
 		swapper:      swapper,
 		session: &session{
 			tunnels: make(map[string]*tunnel),
+			aliases: make(map[string]*tunnel),
 			raw:     swapper,
 			Logger:  newLogger(logger),
 		},
+		retired: make(map[RawSession]struct{}),
+		closing: make(chan struct{}),
 	}
 
 	// setup an initial connection
 	go func() {
+		s.connectMu.Lock()
 		err := s.connect(nil)
+		s.connectMu.Unlock()
 		if err != nil {
 			return
 		}
-		s.receive()
+		s.receive(swapper.get())
 	}()
 
 	return s
@@ -150,37 +173,147 @@ func NewReconnectingSession(logger log.Logger, dialer RawSessionDialer, stateCha
 
 func (s *reconnectingSession) Close() error {
 	atomic.StoreInt32(&s.closed, 1)
+	s.closeOnce.Do(func() { close(s.closing) })
+
+	s.retiredMu.Lock()
+	for raw := range s.retired {
+		_ = raw.Close()
+	}
+	s.retiredMu.Unlock()
+
 	return s.session.Close()
 }
 
-func (s *reconnectingSession) receive() {
-	// when we shut down, close all of the open tunnels
-	defer func() {
-		s.RLock()
-		for _, t := range s.tunnels {
-			go t.Close()
-		}
-		s.RUnlock()
-	}()
-
+// Accepts proxy connections over raw until it fails, then reconnects unless
+// raw was retired by Migrate.
+func (s *reconnectingSession) receive(raw RawSession) {
 	for {
 		// accept the next proxy connection
-		proxy, err := s.raw.Accept()
+		proxy, err := raw.Accept()
 		if err == nil {
 			go s.handleProxy(proxy)
 			continue
 		}
 
+		s.connectMu.Lock()
+		if s.release(raw) {
+			// a newer raw session has taken over, nothing more to do
+			s.connectMu.Unlock()
+			s.Debug("retired session closed", "err", err)
+			return
+		}
+
 		// we disconnected, reconnect
 		err = s.connect(err)
+		raw = s.swapper.get()
+		s.connectMu.Unlock()
 		if err != nil {
 			s.Info("accept failed", "err", err)
-			// permanent failure
+			// permanent failure, close all of the open tunnels
+			closed := atomic.LoadInt32(&s.closed) == 1
+			s.RLock()
+			for _, t := range s.tunnels {
+				if closed {
+					go t.Close()
+				} else {
+					go t.fail()
+				}
+			}
+			s.RUnlock()
 			return
 		}
 	}
 }
 
+// Forgets about a retired raw session. Returns false if raw wasn't retired.
+func (s *reconnectingSession) release(raw RawSession) bool {
+	s.retiredMu.Lock()
+	defer s.retiredMu.Unlock()
+	_, ok := s.retired[raw]
+	delete(s.retired, raw)
+	return ok
+}
+
+// Migrate moves the session onto a newly dialed connection before giving up
+// the current one. The session is re-authenticated and its tunnels re-bound
+// on the new connection, while connections that arrive over the old one
+// continue to be served until drain has elapsed.
+//
+// If the new connection can't be established, the session carries on with
+// the old one and the error is returned.
+func (s *reconnectingSession) Migrate(drain time.Duration) error {
+	if !s.connectMu.TryLock() {
+		return errors.New("session is already reconnecting")
+	}
+	defer s.connectMu.Unlock()
+
+	if atomic.LoadInt32(&s.closed) == 1 {
+		return errors.New("session closed")
+	}
+
+	oldRaw := s.swapper.get()
+	oldClientID := s.clientID
+	s.RLock()
+	oldIDs := make(map[*tunnel]string, len(s.tunnels))
+	for _, t := range s.tunnels {
+		oldIDs[t] = t.ID()
+	}
+	s.RUnlock()
+
+	rollback := func(err error, raw RawSession) error {
+		s.Warn("failed to migrate session, continuing with the current connection", "err", err)
+		s.swapper.set(oldRaw)
+		s.clientID = oldClientID
+		for t, id := range oldIDs {
+			t.id.Store(id)
+		}
+		if raw != nil {
+			raw.Close()
+		}
+		return err
+	}
+
+	raw, err := s.dialer()
+	if err != nil {
+		return rollback(err, raw)
+	}
+
+	s.swapper.set(raw)
+
+	commit, err := s.cb(s)
+	if err != nil {
+		return rollback(err, raw)
+	}
+
+	changed, failed, err := s.restartBinds(raw)
+	if err != nil {
+		return rollback(err, raw)
+	}
+	if commit != nil {
+		commit()
+	}
+	for _, t := range failed {
+		go t.fail()
+	}
+
+	// keep routing connections for the old IDs while the old session drains
+	s.addAliases(changed)
+
+	s.retiredMu.Lock()
+	s.retired[oldRaw] = struct{}{}
+	s.retiredMu.Unlock()
+
+	go s.receive(raw)
+
+	time.AfterFunc(drain, func() {
+		_ = oldRaw.Close()
+		s.delAliases(changed)
+	})
+
+	s.Info("client session migrated")
+	return nil
+}
+
 func (s *reconnectingSession) Auth(extra proto.AuthExtra) (resp proto.AuthResp, err error) {
 	resp, err = s.raw.Auth(s.clientID, extra)
 	if err != nil {
@@ -194,6 +327,13 @@ func (s *reconnectingSession) Auth(extra proto.AuthExtra) (resp proto.AuthResp,
 	return
 }
 
+// Reports whether err says that retrying can't succeed, by implementing
+// Permanent() bool.
+func isPermanent(err error) bool {
+	var perm interface{ Permanent() bool }
+	return errors.As(err, &perm) && perm.Permanent()
+}
+
 func (s *reconnectingSession) connect(acceptErr error) error {
 	boff := &backoff.Backoff{
 		Min:    500 * time.Millisecond,
@@ -214,7 +354,10 @@ func (s *reconnectingSession) connect(acceptErr error) error {
 		// session failed, wait before reconnecting
 		wait := boff.Duration()
 		s.Debug("sleep before reconnect", "secs", int(wait.Seconds()))
-		time.Sleep(wait)
+		select {
+		case <-time.After(wait):
+		case <-s.closing:
+		}
 	}
 
 	failPermanent := func(err error) error {
@@ -223,53 +366,14 @@ func (s *reconnectingSession) connect(acceptErr error) error {
 		return err
 	}
 
-	restartBinds := func(raw RawSession) (err error) {
-		s.Lock()
-		defer s.Unlock()
-
-		// reconnected tunnels, which may have different IDs
-		newTunnels := make(map[string]*tunnel, len(s.tunnels))
-		for oldID, t := range s.tunnels {
-			// set the returned token for reconnection
-			tCfg := t.RemoteBindConfig()
-			t.bindExtra.Token = tCfg.Token
-
-			var respErr string
-			if tCfg.Labels != nil {
-				resp, err := raw.ListenLabel(tCfg.Labels, tCfg.Metadata, t.ForwardsTo())
-				if err != nil {
-					return err
-				}
-				respErr = resp.Error
-				if resp.ID != "" {
-					t.id.Store(resp.ID)
-					newTunnels[resp.ID] = t
-				} else {
-					// Otherwise save the old tunnel I guess? Maybe next reconnect gets it?
-					// This doesn't seem quite right though...
-					newTunnels[oldID] = t
-				}
-			} else {
-				resp, err := raw.Listen(tCfg.ConfigProto, tCfg.Opts, t.bindExtra, t.ID(), t.ForwardsTo())
-				if err != nil {
-					return err
-				}
-				respErr = resp.Error
-				// same ID, no need to change
-				newTunnels[oldID] = t
-			}
-
-			if respErr != "" {
-				return errors.New(respErr)
-			}
-		}
-		s.tunnels = newTunnels
-		return nil
-	}
-
 	if acceptErr != nil {
 		if atomic.LoadInt32(&s.closed) == 0 {
 			s.Error("session closed, starting reconnect loop", "err", acceptErr)
+			s.RLock()
+			for _, t := range s.tunnels {
+				t.status.set(TunnelReconnecting, "")
+			}
+			s.RUnlock()
 			s.stateChanges <- acceptErr
 		}
 	}
@@ -294,17 +398,29 @@ func (s *reconnectingSession) connect(acceptErr error) error {
 		s.swapper.set(raw)
 
 		// callback for authentication
-		if err := s.cb(s); err != nil {
+		commit, err := s.cb(s)
+		if err != nil {
+			if isPermanent(err) {
+				s.Error("failed to reconnect session, not retrying", "err", err)
+				raw.Close()
+				return failPermanent(err)
+			}
 			failTemp(err, raw)
 			continue
 		}
 
 		// re-establish binds
-		err = restartBinds(raw)
+		_, failed, err := s.restartBinds(raw)
 		if err != nil {
 			failTemp(err, raw)
 			continue
 		}
+		if commit != nil {
+			commit()
+		}
+		for _, t := range failed {
+			go t.fail()
+		}
 
 		// reset wait
 		boff.Reset()
@@ -314,3 +430,97 @@ func (s *reconnectingSession) connect(acceptErr error) error {
 		return nil
 	}
 }
+
+// Re-establishes the binds for every tunnel over raw. Returns the tunnels
+// whose IDs changed, keyed by their old ID, and those whose options couldn't
+// be resolved. The latter are left out of the session and should be failed;
+// they don't prevent the other tunnels from being re-established.
+func (s *reconnectingSession) restartBinds(raw RawSession) (changed map[string]*tunnel, failed []*tunnel, err error) {
+	// resolve options before taking the lock, since resolving them may be
+	// slow
+	s.RLock()
+	resolvers := make(map[*tunnel]func() (any, error))
+	for _, t := range s.tunnels {
+		if resolve := t.resolveOpts; resolve != nil {
+			current := t.RemoteBindConfig().Opts
+			resolvers[t] = func() (any, error) { return resolve(current) }
+		}
+	}
+	s.RUnlock()
+	resolved := make(map[*tunnel]any, len(resolvers))
+	for t, resolve := range resolvers {
+		opts, err := resolve()
+		if err != nil {
+			s.Warn("failed to resolve tunnel options, closing it", "id", t.ID(), "err", err)
+			failed = append(failed, t)
+			continue
+		}
+		resolved[t] = opts
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// reconnected tunnels, which may have different IDs
+	newTunnels := make(map[string]*tunnel, len(s.tunnels))
+	changed = make(map[string]*tunnel)
+	// the new URL and options of each tunnel, applied once all have succeeded
+	type rebind struct {
+		prevID string
+		url    string
+		opts   any
+	}
+	rebinds := make(map[*tunnel]rebind, len(s.tunnels))
+	for oldID, t := range s.tunnels {
+		opts, ok := resolved[t]
+		if _, resolves := resolvers[t]; resolves && !ok {
+			continue
+		}
+
+		// set the returned token for reconnection
+		tCfg := t.RemoteBindConfig()
+		t.bindExtra.Token = tCfg.Token
+
+		var respErr string
+		if tCfg.Labels != nil {
+			resp, err := raw.ListenLabel(tCfg.Labels, tCfg.Metadata, t.ForwardsTo())
+			if err != nil {
+				return nil, nil, err
+			}
+			respErr = resp.Error
+			rebinds[t] = rebind{prevID: oldID}
+			if resp.ID != "" {
+				t.id.Store(resp.ID)
+				newTunnels[resp.ID] = t
+				if resp.ID != oldID {
+					changed[oldID] = t
+				}
+			} else {
+				// Otherwise save the old tunnel I guess? Maybe next reconnect gets it?
+				// This doesn't seem quite right though...
+				newTunnels[oldID] = t
+			}
+		} else {
+			if !ok {
+				opts = tCfg.Opts
+			}
+			resp, err := raw.Listen(tCfg.ConfigProto, opts, t.bindExtra, t.ID(), t.ForwardsTo())
+			if err != nil {
+				return nil, nil, err
+			}
+			respErr = resp.Error
+			rebinds[t] = rebind{prevID: oldID, url: resp.URL, opts: resp.Opts}
+			// same ID, no need to change
+			newTunnels[oldID] = t
+		}
+
+		if respErr != "" {
+			return nil, nil, errors.New(respErr)
+		}
+	}
+	s.tunnels = newTunnels
+	for t, r := range rebinds {
+		t.rebound(r.prevID, r.url, r.opts)
+	}
+	return changed, failed, nil
+}
@@ -0,0 +1,576 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inconshreveable/log15/v3"
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok/internal/tunnel/netx"
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+// A RawSession that binds every labeled tunnel with a fresh ID and never
+// receives any connections.
+type fakeRaw struct {
+	name      string
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newFakeRaw(name string) *fakeRaw {
+	return &fakeRaw{name: name, closed: make(chan struct{})}
+}
+
+func (r *fakeRaw) Auth(id string, extra proto.AuthExtra) (proto.AuthResp, error) {
+	return proto.AuthResp{ClientID: "client"}, nil
+}
+
+func (r *fakeRaw) Listen(protocol string, opts any, extra proto.BindExtra, id string, forwardsTo string) (proto.BindResp, error) {
+	// new binds are identified by their protocol, rebinds keep their ID
+	if id == "" {
+		id = "bind-" + protocol
+	}
+	// stands in for the options the server assigns, such as a hostname
+	return proto.BindResp{ClientID: id, Proto: protocol, Opts: "assigned-" + id}, nil
+}
+
+func (r *fakeRaw) ListenLabel(labels map[string]string, metadata string, forwardsTo string) (proto.StartTunnelWithLabelResp, error) {
+	return proto.StartTunnelWithLabelResp{ID: "tunnel-" + r.name}, nil
+}
+
+func (r *fakeRaw) Unlisten(id string) (proto.UnbindResp, error) {
+	return proto.UnbindResp{}, nil
+}
+
+func (r *fakeRaw) Accept() (netx.LoggedConn, error) {
+	<-r.closed
+	return nil, errors.New("closed")
+}
+
+func (r *fakeRaw) SrvInfo() (proto.SrvInfoResp, error) {
+	return proto.SrvInfoResp{}, nil
+}
+
+func (r *fakeRaw) Latency() <-chan time.Duration {
+	return nil
+}
+
+func (r *fakeRaw) Heartbeat() (time.Duration, error) {
+	return 0, nil
+}
+
+func (r *fakeRaw) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return nil
+}
+
+func (r *fakeRaw) isClosed() bool {
+	select {
+	case <-r.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Starts a reconnecting session over the raw sessions returned by dial, and
+// waits for it to connect.
+func connectFake(t *testing.T, dial RawSessionDialer) (*reconnectingSession, chan error) {
+	stateChanges := make(chan error, 32)
+	sess := NewReconnectingSession(log15.New(), dial, stateChanges, func(s Session) (func(), error) {
+		_, err := s.Auth(proto.AuthExtra{})
+		return nil, err
+	}).(*reconnectingSession)
+	t.Cleanup(func() { _ = sess.Close() })
+
+	select {
+	case err := <-stateChanges:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("session didn't connect")
+	}
+	return sess, stateChanges
+}
+
+func TestReconnectingSessionMigrate(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		raws  []*fakeRaw
+		count int
+	)
+	sess, stateChanges := connectFake(t, func() (RawSession, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+		raw := newFakeRaw(fmt.Sprint(count))
+		raws = append(raws, raw)
+		return raw, nil
+	})
+
+	tun, err := sess.ListenLabel(map[string]string{"edge": "edghts_123"}, "", "")
+	require.NoError(t, err)
+	require.Equal(t, "tunnel-1", tun.ID())
+
+	require.NoError(t, sess.Migrate(100*time.Millisecond))
+
+	mu.Lock()
+	oldRaw, newRaw := raws[0], raws[1]
+	mu.Unlock()
+
+	require.Equal(t, RawSession(newRaw), sess.swapper.get())
+	require.Equal(t, "tunnel-2", tun.ID())
+
+	// the old session keeps serving until it's drained
+	require.False(t, oldRaw.isClosed())
+	_, ok := sess.getTunnel("tunnel-1")
+	require.True(t, ok)
+	_, ok = sess.getTunnel("tunnel-2")
+	require.True(t, ok)
+
+	require.Eventually(t, oldRaw.isClosed, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		_, ok := sess.getTunnel("tunnel-1")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+
+	// the retired session closing isn't a disconnect
+	select {
+	case err := <-stateChanges:
+		t.Fatalf("unexpected state change: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.False(t, newRaw.isClosed())
+}
+
+func TestReconnectingSessionMigrateRollback(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		raws []*fakeRaw
+		fail bool
+	)
+	sess, _ := connectFake(t, func() (RawSession, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return nil, errors.New("dial failed")
+		}
+		raw := newFakeRaw(fmt.Sprint(len(raws) + 1))
+		raws = append(raws, raw)
+		return raw, nil
+	})
+
+	tun, err := sess.ListenLabel(map[string]string{"edge": "edghts_123"}, "", "")
+	require.NoError(t, err)
+
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+
+	require.Error(t, sess.Migrate(time.Second))
+
+	mu.Lock()
+	require.Len(t, raws, 1)
+	require.Equal(t, RawSession(raws[0]), sess.swapper.get())
+	require.False(t, raws[0].isClosed())
+	mu.Unlock()
+	require.Equal(t, "tunnel-1", tun.ID())
+}
+
+// A fakeRaw whose labeled tunnels can't be started.
+type rebindFailRaw struct {
+	*fakeRaw
+}
+
+func (r *rebindFailRaw) ListenLabel(labels map[string]string, metadata string, forwardsTo string) (proto.StartTunnelWithLabelResp, error) {
+	return proto.StartTunnelWithLabelResp{}, errors.New("rebind failed")
+}
+
+func TestReconnectingSessionMigrateRollbackSkipsCommit(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		raws    []RawSession
+		commits []RawSession
+	)
+	stateChanges := make(chan error, 32)
+	sess := NewReconnectingSession(log15.New(), func() (RawSession, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		var raw RawSession = newFakeRaw(fmt.Sprint(len(raws) + 1))
+		if len(raws) > 0 {
+			raw = &rebindFailRaw{fakeRaw: newFakeRaw(fmt.Sprint(len(raws) + 1))}
+		}
+		raws = append(raws, raw)
+		return raw, nil
+	}, stateChanges, func(s Session) (func(), error) {
+		if _, err := s.Auth(proto.AuthExtra{}); err != nil {
+			return nil, err
+		}
+		raw := s.(*reconnectingSession).swapper.get()
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			commits = append(commits, raw)
+		}, nil
+	}).(*reconnectingSession)
+	t.Cleanup(func() { _ = sess.Close() })
+	require.NoError(t, <-stateChanges)
+
+	tun, err := sess.ListenLabel(map[string]string{"edge": "edghts_123"}, "", "")
+	require.NoError(t, err)
+
+	require.Error(t, sess.Migrate(time.Second))
+
+	// only the connection that's still in use was committed
+	mu.Lock()
+	require.Len(t, raws, 2)
+	require.Equal(t, []RawSession{raws[0]}, commits)
+	require.Equal(t, raws[0], sess.swapper.get())
+	mu.Unlock()
+	require.Equal(t, "tunnel-1", tun.ID())
+}
+
+func TestReconnectingSessionCloseDuringBackoff(t *testing.T) {
+	stateChanges := make(chan error, 32)
+	sess := NewReconnectingSession(log15.New(), func() (RawSession, error) {
+		return nil, errors.New("unreachable")
+	}, stateChanges, func(s Session) (func(), error) {
+		return nil, nil
+	})
+
+	require.Error(t, <-stateChanges)
+	start := time.Now()
+	require.NoError(t, sess.Close())
+
+	// the session gives up without waiting out the backoff
+	for range stateChanges {
+	}
+	require.Less(t, time.Since(start), 250*time.Millisecond)
+}
+
+func TestReconnectingSessionTunnelStatus(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		raws  []*fakeRaw
+		count int
+	)
+	sess, stateChanges := connectFake(t, func() (RawSession, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+		raw := newFakeRaw(fmt.Sprint(count))
+		raws = append(raws, raw)
+		return raw, nil
+	})
+
+	tun, err := sess.ListenLabel(map[string]string{"edge": "edghts_123"}, "", "")
+	require.NoError(t, err)
+
+	type change struct {
+		status TunnelStatus
+		prevID string
+	}
+	changes := make(chan change, 8)
+	tun.ObserveStatus(func(status TunnelStatus, prevID string) {
+		changes <- change{status, prevID}
+	})
+	require.Equal(t, change{TunnelOnline, ""}, <-changes)
+
+	// drop the connection
+	mu.Lock()
+	_ = raws[0].Close()
+	mu.Unlock()
+
+	require.Equal(t, change{TunnelReconnecting, ""}, <-changes)
+	require.Equal(t, change{TunnelOnline, "tunnel-1"}, <-changes)
+	require.Equal(t, "tunnel-2", tun.ID())
+	require.Equal(t, TunnelOnline, tun.Status())
+	require.Error(t, <-stateChanges)
+	require.NoError(t, <-stateChanges)
+
+	// closing the session closes the tunnel
+	require.NoError(t, sess.Close())
+	mu.Lock()
+	_ = raws[1].Close()
+	mu.Unlock()
+	require.Equal(t, change{TunnelClosed, ""}, <-changes)
+}
+
+// A fakeRaw which starts labeled tunnels with a new ID each time, and records
+// the metadata they were started with.
+type relabelRaw struct {
+	*fakeRaw
+
+	mu        sync.Mutex
+	started   int
+	metadata  []string
+	unlistens []string
+}
+
+func (r *relabelRaw) ListenLabel(labels map[string]string, metadata string, forwardsTo string) (proto.StartTunnelWithLabelResp, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started++
+	r.metadata = append(r.metadata, metadata)
+	return proto.StartTunnelWithLabelResp{ID: fmt.Sprintf("tunnel-%s-%d", r.name, r.started)}, nil
+}
+
+func (r *relabelRaw) Unlisten(id string) (proto.UnbindResp, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unlistens = append(r.unlistens, id)
+	return proto.UnbindResp{}, nil
+}
+
+func TestReconnectingSessionUpdateMetadata(t *testing.T) {
+	raw := &relabelRaw{fakeRaw: newFakeRaw("1")}
+	sess, _ := connectFake(t, func() (RawSession, error) {
+		return raw, nil
+	})
+
+	tun, err := sess.ListenLabel(map[string]string{"edge": "edghts_123"}, "old", "")
+	require.NoError(t, err)
+	require.Equal(t, "tunnel-1-1", tun.ID())
+
+	type change struct {
+		status TunnelStatus
+		prevID string
+	}
+	changes := make(chan change, 8)
+	tun.ObserveStatus(func(status TunnelStatus, prevID string) {
+		changes <- change{status, prevID}
+	})
+	require.Equal(t, change{TunnelOnline, ""}, <-changes)
+
+	require.NoError(t, tun.SetMetadata("new"))
+	require.Equal(t, "tunnel-1-2", tun.ID())
+	require.Equal(t, "new", tun.RemoteBindConfig().Metadata)
+	require.Equal(t, change{TunnelOnline, "tunnel-1-1"}, <-changes)
+
+	raw.mu.Lock()
+	require.Equal(t, []string{"old", "new"}, raw.metadata)
+	require.Equal(t, []string{"tunnel-1-1"}, raw.unlistens)
+	raw.mu.Unlock()
+
+	_, ok := sess.getTunnel("tunnel-1-1")
+	require.False(t, ok)
+	_, ok = sess.getTunnel("tunnel-1-2")
+	require.True(t, ok)
+
+	// closing unlistens the new ID
+	require.NoError(t, tun.Close())
+	raw.mu.Lock()
+	require.Equal(t, []string{"tunnel-1-1", "tunnel-1-2"}, raw.unlistens)
+	raw.mu.Unlock()
+}
+
+func TestReconnectingSessionResolveFailure(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		raws  []*fakeRaw
+		count int
+	)
+	sess, stateChanges := connectFake(t, func() (RawSession, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+		raw := newFakeRaw(fmt.Sprint(count))
+		raws = append(raws, raw)
+		return raw, nil
+	})
+
+	broken, err := sess.ListenWithResolver("tcp", nil, func(current any) (any, error) {
+		return nil, errors.New("secret unavailable")
+	}, proto.BindExtra{}, "")
+	require.NoError(t, err)
+	resolvedFrom := make(chan any, 1)
+	healthy, err := sess.ListenWithResolver("http", nil, func(current any) (any, error) {
+		resolvedFrom <- current
+		return current, nil
+	}, proto.BindExtra{}, "")
+	require.NoError(t, err)
+
+	// drop the connection
+	mu.Lock()
+	_ = raws[0].Close()
+	mu.Unlock()
+	require.Error(t, <-stateChanges)
+	require.NoError(t, <-stateChanges, "one tunnel failing to resolve shouldn't fail the reconnect")
+
+	require.Eventually(t, func() bool {
+		return broken.Status() == TunnelFailed
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, TunnelOnline, healthy.Status())
+	// secrets are resolved into the options the server assigned
+	require.Equal(t, "assigned-bind-http", <-resolvedFrom)
+}
+
+type permanentError struct{}
+
+func (permanentError) Error() string   { return "rejected" }
+func (permanentError) Permanent() bool { return true }
+
+func TestReconnectingSessionPermanentFailure(t *testing.T) {
+	var dials int
+	stateChanges := make(chan error, 32)
+	sess := NewReconnectingSession(log15.New(), func() (RawSession, error) {
+		dials++
+		return newFakeRaw(fmt.Sprint(dials)), nil
+	}, stateChanges, func(s Session) (func(), error) {
+		return nil, fmt.Errorf("auth: %w", permanentError{})
+	})
+	defer sess.Close()
+
+	var errs []error
+	timeout := time.After(time.Second)
+	for done := false; !done; {
+		select {
+		case err, ok := <-stateChanges:
+			if !ok {
+				done = true
+				break
+			}
+			errs = append(errs, err)
+		case <-timeout:
+			t.Fatal("session kept retrying")
+		}
+	}
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], permanentError{})
+	require.Equal(t, 1, dials)
+}
+
+func TestReconnectingSessionUpdateMetadataSameID(t *testing.T) {
+	sess, _ := connectFake(t, func() (RawSession, error) {
+		return newFakeRaw("1"), nil
+	})
+
+	tun, err := sess.ListenLabel(map[string]string{"edge": "edghts_123"}, "old", "")
+	require.NoError(t, err)
+
+	changes := make(chan TunnelStatus, 8)
+	tun.ObserveStatus(func(status TunnelStatus, prevID string) {
+		changes <- status
+	})
+	require.Equal(t, TunnelOnline, <-changes)
+
+	require.NoError(t, tun.SetMetadata("new"))
+	require.Equal(t, "tunnel-1", tun.ID())
+	require.Equal(t, "new", tun.RemoteBindConfig().Metadata)
+	select {
+	case status := <-changes:
+		t.Fatalf("unexpected status change to %v", status)
+	default:
+	}
+}
+
+// A relabelRaw which holds ListenLabel calls until released.
+type gatedRaw struct {
+	*relabelRaw
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (r *gatedRaw) ListenLabel(labels map[string]string, metadata string, forwardsTo string) (proto.StartTunnelWithLabelResp, error) {
+	if metadata == "gated" {
+		r.entered <- struct{}{}
+		<-r.release
+	}
+	return r.relabelRaw.ListenLabel(labels, metadata, forwardsTo)
+}
+
+func TestReconnectingSessionUpdateMetadataPendingID(t *testing.T) {
+	raw := &gatedRaw{
+		relabelRaw: &relabelRaw{fakeRaw: newFakeRaw("1")},
+		entered:    make(chan struct{}),
+		release:    make(chan struct{}),
+	}
+	sess, _ := connectFake(t, func() (RawSession, error) {
+		return raw, nil
+	})
+
+	tun, err := sess.ListenLabel(map[string]string{"edge": "edghts_123"}, "old", "")
+	require.NoError(t, err)
+
+	updated := make(chan error, 1)
+	go func() {
+		updated <- tun.SetMetadata("gated")
+	}()
+	<-raw.entered
+
+	// a connection routed to the new ID before the response arrives waits
+	// for it to be registered
+	found := make(chan bool, 1)
+	go func() {
+		_, ok := sess.awaitTunnel("tunnel-1-2")
+		found <- ok
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(raw.release)
+
+	require.NoError(t, <-updated)
+	require.True(t, <-found)
+
+	// nothing is pending any more, so unknown IDs are rejected straight away
+	_, ok := sess.awaitTunnel("tunnel-unknown")
+	require.False(t, ok)
+}
+
+// A fakeRaw which records the binds it's asked for.
+type bindRecordingRaw struct {
+	*fakeRaw
+
+	mu    sync.Mutex
+	binds []proto.BindExtra
+	ids   []string
+}
+
+func (r *bindRecordingRaw) Listen(protocol string, opts any, extra proto.BindExtra, id string, forwardsTo string) (proto.BindResp, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.binds = append(r.binds, extra)
+	r.ids = append(r.ids, id)
+	resp, err := r.fakeRaw.Listen(protocol, opts, extra, id, forwardsTo)
+	resp.URL = fmt.Sprintf("https://%d.example.com", len(r.binds))
+	resp.Extra.Token = "token"
+	return resp, err
+}
+
+func TestReconnectingSessionUpdateMetadataEndpoint(t *testing.T) {
+	raw := &bindRecordingRaw{fakeRaw: newFakeRaw("1")}
+	sess, _ := connectFake(t, func() (RawSession, error) {
+		return raw, nil
+	})
+
+	tun, err := sess.Listen("https", &proto.HTTPEndpoint{}, proto.BindExtra{Metadata: "old"}, "")
+	require.NoError(t, err)
+	require.Equal(t, "bind-https", tun.ID())
+
+	changes := make(chan TunnelStatus, 8)
+	tun.ObserveStatus(func(status TunnelStatus, prevID string) {
+		changes <- status
+	})
+	require.Equal(t, TunnelOnline, <-changes)
+
+	require.NoError(t, tun.SetMetadata("new"))
+	require.Equal(t, "bind-https", tun.ID())
+	require.Equal(t, "new", tun.RemoteBindConfig().Metadata)
+	require.Equal(t, "https://2.example.com", tun.RemoteBindConfig().URL)
+
+	raw.mu.Lock()
+	// re-bound in place, with the ID and token from the first bind
+	require.Equal(t, []string{"", "bind-https"}, raw.ids)
+	require.Equal(t, "new", raw.binds[1].Metadata)
+	require.Equal(t, "token", raw.binds[1].Token)
+	raw.mu.Unlock()
+
+	select {
+	case status := <-changes:
+		t.Fatalf("unexpected status change to %v", status)
+	default:
+	}
+}
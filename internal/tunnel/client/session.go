@@ -41,6 +41,11 @@ type Session interface {
 	// ListenHTTP, ListenTCP, etc.
 	Listen(protocol string, opts any, extra proto.BindExtra, forwardsTo string) (Tunnel, error)
 
+	// ListenWithResolver is like Listen, but calls resolve to get fresh
+	// options each time the tunnel is re-bound after a reconnect. A nil
+	// resolve re-uses the options returned by the server.
+	ListenWithResolver(protocol string, opts any, resolve BindOptsResolver, extra proto.BindExtra, forwardsTo string) (Tunnel, error)
+
 	// Listen negotiates with the server to create a new remote listen for the
 	// given labels. It returns a *Tunnel on success from which the caller can
 	// accept new connections over the listen.
@@ -77,8 +82,19 @@ type session struct {
 	sync.RWMutex
 	log.Logger
 	tunnels map[string]*tunnel
+	// old IDs of tunnels that are still in use by a draining connection
+	aliases map[string]*tunnel
+
+	// the number of binds whose new ID may be routed to before it's been
+	// registered, and a channel closed once there are none
+	pendingBinds int
+	bindsDone    chan struct{}
 }
 
+// How long a proxy connection for an unknown ID waits for pending binds to
+// register their IDs.
+const pendingBindTimeout = 5 * time.Second
+
 // NewSession starts a new go-tunnel client session running over the given
 // muxado session.
 func NewSession(logger log.Logger, mux muxado.Session, heartbeatConfig *muxado.HeartbeatConfig, handler SessionHandler) Session {
@@ -87,6 +103,7 @@ func NewSession(logger log.Logger, mux muxado.Session, heartbeatConfig *muxado.H
 		raw:     newRawSession(mux, logger, heartbeatConfig, handler),
 		Logger:  logger,
 		tunnels: make(map[string]*tunnel),
+		aliases: make(map[string]*tunnel),
 	}
 
 	go s.receive()
@@ -113,7 +130,15 @@ func (s *session) Heartbeat() (time.Duration, error) {
 	return s.raw.Heartbeat()
 }
 
+// BindOptsResolver produces the options to re-bind a tunnel with, given
+// current, the options the server returned when it was last bound.
+type BindOptsResolver func(current any) (any, error)
+
 func (s *session) Listen(protocol string, opts any, extra proto.BindExtra, forwardsTo string) (Tunnel, error) {
+	return s.ListenWithResolver(protocol, opts, nil, extra, forwardsTo)
+}
+
+func (s *session) ListenWithResolver(protocol string, opts any, resolve BindOptsResolver, extra proto.BindExtra, forwardsTo string) (Tunnel, error) {
 	resp, err := s.raw.Listen(protocol, opts, extra, "", forwardsTo)
 	if err != nil {
 		return nil, err
@@ -126,6 +151,7 @@ func (s *session) Listen(protocol string, opts any, extra proto.BindExtra, forwa
 
 	// make tunnel
 	t := newTunnel(resp, extra, s, forwardsTo)
+	t.resolveOpts = resolve
 
 	// add to tunnel registry
 	s.addTunnel(resp.ClientID, t)
@@ -218,6 +244,9 @@ func (s *session) handleProxy(proxy netx.LoggedConn) {
 
 	// find tunnel
 	tunnel, ok := s.getTunnel(proxyHdr.ID)
+	if !ok {
+		tunnel, ok = s.awaitTunnel(proxyHdr.ID)
+	}
 	if !ok {
 		proxyError("no tunnel found for proxy", "id", proxyHdr.ID)
 		return
@@ -260,13 +289,148 @@ func (s *session) unlisten(bindID string) error {
 	return nil
 }
 
+// Re-binds t with new metadata. A labeled tunnel is started again alongside
+// the current one, which is stopped once connections are routed to the new
+// ID. Other tunnels are re-bound in place, as they are after a reconnect.
+func (s *session) updateMetadata(t *tunnel, metadata string) error {
+	if t.labels != nil {
+		// connections may be routed to the new ID before the response
+		// arrives, so hold them until it's registered
+		bindDone := s.startPendingBind()
+		resp, err := s.raw.ListenLabel(t.labels, metadata, t.forwardsTo)
+		if err == nil && resp.Error != "" {
+			err = errors.New(resp.Error)
+		}
+		if err != nil {
+			bindDone()
+			return err
+		}
+
+		oldID := t.ID()
+		s.Lock()
+		t.bindMu.Lock()
+		t.bindExtra.Metadata = metadata
+		t.bindMu.Unlock()
+		if resp.ID == oldID {
+			s.Unlock()
+			bindDone()
+			return nil
+		}
+		delete(s.tunnels, oldID)
+		s.tunnels[resp.ID] = t
+		// connections may still arrive for the old ID until it's stopped
+		s.aliases[oldID] = t
+		t.id.Store(resp.ID)
+		s.Unlock()
+		bindDone()
+
+		resp2, err := s.raw.Unlisten(oldID)
+		s.delAliases(map[string]*tunnel{oldID: t})
+		if err == nil && resp2.Error != "" {
+			err = errors.New(resp2.Error)
+		}
+		if err != nil {
+			s.Warn("failed to stop tunnel after updating its metadata", "id", oldID, "err", err)
+		}
+		t.status.set(TunnelOnline, oldID)
+		return nil
+	}
+
+	cfg := t.RemoteBindConfig()
+	s.RLock()
+	extra := t.bindExtra
+	s.RUnlock()
+	extra.Metadata = metadata
+	extra.Token = cfg.Token
+	resp, err := s.raw.Listen(cfg.ConfigProto, cfg.Opts, extra, t.ID(), t.forwardsTo)
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+
+	s.Lock()
+	t.bindMu.Lock()
+	t.bindExtra.Metadata = metadata
+	t.bindMu.Unlock()
+	s.Unlock()
+	// the ID is unchanged, so there's nothing for status observers to do
+	t.updateBind(resp.URL, resp.Opts)
+	return nil
+}
+
+// Records a bind in progress whose new ID isn't known yet. The returned
+// function must be called once the ID has been registered, or the bind
+// failed.
+func (s *session) startPendingBind() func() {
+	s.Lock()
+	defer s.Unlock()
+	if s.pendingBinds == 0 {
+		s.bindsDone = make(chan struct{})
+	}
+	s.pendingBinds++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.Lock()
+			defer s.Unlock()
+			s.pendingBinds--
+			if s.pendingBinds == 0 {
+				close(s.bindsDone)
+				s.bindsDone = nil
+			}
+		})
+	}
+}
+
+// Looks up a tunnel by an ID that may belong to a pending bind, waiting for
+// the pending binds to finish first.
+func (s *session) awaitTunnel(id string) (*tunnel, bool) {
+	s.RLock()
+	done := s.bindsDone
+	s.RUnlock()
+	if done == nil {
+		// the bind may have finished since the tunnel was last looked up
+		return s.getTunnel(id)
+	}
+
+	timer := time.NewTimer(pendingBindTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+	return s.getTunnel(id)
+}
+
 func (s *session) getTunnel(id string) (t *tunnel, ok bool) {
 	s.RLock()
 	defer s.RUnlock()
 	t, ok = s.tunnels[id]
+	if !ok {
+		t, ok = s.aliases[id]
+	}
 	return
 }
 
+func (s *session) addAliases(aliases map[string]*tunnel) {
+	s.Lock()
+	defer s.Unlock()
+	for id, t := range aliases {
+		s.aliases[id] = t
+	}
+}
+
+func (s *session) delAliases(aliases map[string]*tunnel) {
+	s.Lock()
+	defer s.Unlock()
+	for id := range aliases {
+		delete(s.aliases, id)
+	}
+}
+
 func (s *session) addTunnel(id string, t *tunnel) {
 	s.Lock()
 	defer s.Unlock()
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"net"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 
 	"golang.ngrok.com/ngrok/internal/tunnel/proto"
@@ -16,6 +19,13 @@ type Tunnel interface {
 	RemoteBindConfig() *RemoteBindConfig
 	ID() string
 	ForwardsTo() string
+	// SetMetadata re-binds the tunnel with new metadata, without
+	// interrupting its connections.
+	SetMetadata(metadata string) error
+	Status() TunnelStatus
+	// ObserveStatus sets a function to call whenever the tunnel's status
+	// changes. It's called right away with the current status.
+	ObserveStatus(observer TunnelStatusObserver)
 }
 
 type ProxyConn struct {
@@ -28,48 +38,58 @@ type ProxyConn struct {
 type tunnel struct {
 	id          atomic.Value
 	configProto string
+	// the url and opts can change when re-bound
+	bindMu      sync.RWMutex
 	url         string
 	opts        any
 	token       string
 	bindExtra   proto.BindExtra
 	labels      map[string]string
 	forwardsTo  string
+	resolveOpts BindOptsResolver
 
 	accept   chan *ProxyConn // new connections come on this channel
 	unlisten func() error    // call this function to close the tunnel
+	// call this function to re-bind the tunnel with new metadata
+	updateMetadata func(metadata string) error
 
 	shut shutdown // for clean shutdowns
+
+	status tunnelStatus
 }
 
 func newTunnel(resp proto.BindResp, extra proto.BindExtra, s *session, forwardsTo string) *tunnel {
-	id := atomic.Value{}
-	id.Store(resp.ClientID)
-	return &tunnel{
-		id:          id,
+	t := &tunnel{
 		configProto: resp.Proto,
 		url:         resp.URL,
 		opts:        resp.Opts,
 		token:       resp.Extra.Token,
 		bindExtra:   extra, // this makes the reconnecting session a little easier
 		accept:      make(chan *ProxyConn),
-		unlisten:    func() error { return s.unlisten(resp.ClientID) },
 		forwardsTo:  forwardsTo,
+		status:      tunnelStatus{status: TunnelOnline},
 	}
+	t.id.Store(resp.ClientID)
+	t.unlisten = func() error { return s.unlisten(t.ID()) }
+	t.updateMetadata = func(metadata string) error { return s.updateMetadata(t, metadata) }
+	return t
 }
 
 func newTunnelLabel(resp proto.StartTunnelWithLabelResp, metadata string, labels map[string]string, s *session, forwardsTo string) *tunnel {
-	id := atomic.Value{}
-	id.Store(resp.ID)
-	return &tunnel{
-		id: id,
+	t := &tunnel{
 		bindExtra: proto.BindExtra{
 			Metadata: metadata,
 		}, // this makes the reconnecting session a little easier
 		labels:     labels,
 		accept:     make(chan *ProxyConn),
-		unlisten:   func() error { return s.unlisten(resp.ID) },
 		forwardsTo: forwardsTo,
+		status:     tunnelStatus{status: TunnelOnline},
 	}
+	t.id.Store(resp.ID)
+	// the ID may change when re-bound
+	t.unlisten = func() error { return s.unlisten(t.ID()) }
+	t.updateMetadata = func(metadata string) error { return s.updateMetadata(t, metadata) }
+	return t
 }
 
 func (t *tunnel) handleConn(r *ProxyConn) {
@@ -91,6 +111,7 @@ func (t *tunnel) Accept() (*ProxyConn, error) {
 // Closes the Tunnel by asking the remote machine to deallocate its listener, or
 // an error if the request failed.
 func (t *tunnel) Close() (err error) {
+	t.status.set(TunnelClosed, "")
 	t.shut.Shut(func() {
 		err = t.unlisten()
 		close(t.accept)
@@ -98,6 +119,43 @@ func (t *tunnel) Close() (err error) {
 	return
 }
 
+// Closes the tunnel because its session gave up reconnecting.
+func (t *tunnel) fail() {
+	t.status.set(TunnelFailed, "")
+	_ = t.Close()
+}
+
+// Records the URL and options the tunnel was re-bound with, and marks it
+// online.
+func (t *tunnel) rebound(prevID string, url string, opts any) {
+	t.updateBind(url, opts)
+	t.status.set(TunnelOnline, prevID)
+}
+
+// Records the URL and options the server returned for a bind, if any.
+func (t *tunnel) updateBind(url string, opts any) {
+	t.bindMu.Lock()
+	defer t.bindMu.Unlock()
+	if url != "" {
+		t.url = url
+	}
+	if opts != nil {
+		t.opts = opts
+	}
+}
+
+func (t *tunnel) SetMetadata(metadata string) error {
+	return t.updateMetadata(metadata)
+}
+
+func (t *tunnel) Status() TunnelStatus {
+	return t.status.get()
+}
+
+func (t *tunnel) ObserveStatus(observer TunnelStatusObserver) {
+	t.status.observe(observer)
+}
+
 // Addr returns the address of the public endpoint of the tunnel listener on the
 // remote machine.
 func (t *tunnel) Addr() net.Addr {
@@ -117,6 +175,8 @@ func (t *tunnel) ID() string {
 // RemoteBindConfig returns more detailed information about the public endpoint of the
 // tunnel listener on the remote machine.
 func (t *tunnel) RemoteBindConfig() *RemoteBindConfig {
+	t.bindMu.RLock()
+	defer t.bindMu.RUnlock()
 	return &RemoteBindConfig{
 		URL:         t.url,
 		ConfigProto: t.configProto,
@@ -140,10 +200,22 @@ func (a *RemoteBindConfig) Network() string {
 	return "tcp"
 }
 
+// String returns the host and port of the endpoint, or the URL itself if it
+// can't be parsed. Labeled tunnels don't have a URL, so their labels are
+// returned instead.
 func (a *RemoteBindConfig) String() string {
+	if a.URL == "" && len(a.Labels) > 0 {
+		labels := make([]string, 0, len(a.Labels))
+		for k, v := range a.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		return strings.Join(labels, ",")
+	}
+
 	u, err := url.Parse(a.URL)
-	if err != nil {
-		panic(err)
+	if err != nil || u.Host == "" {
+		return a.URL
 	}
 	return u.Host
 }
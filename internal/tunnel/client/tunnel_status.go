@@ -0,0 +1,82 @@
+package client
+
+import "sync"
+
+// TunnelStatus is whether a tunnel can currently receive connections.
+type TunnelStatus int
+
+const (
+	// The tunnel is waiting to be bound for the first time.
+	TunnelBinding TunnelStatus = iota
+	// The tunnel is bound and receiving connections.
+	TunnelOnline
+	// The session lost its connection, and the tunnel will be re-bound once
+	// it reconnects.
+	TunnelReconnecting
+	// The session gave up reconnecting, so the tunnel won't come back.
+	TunnelFailed
+	// The tunnel was closed.
+	TunnelClosed
+)
+
+func (s TunnelStatus) String() string {
+	switch s {
+	case TunnelBinding:
+		return "binding"
+	case TunnelOnline:
+		return "online"
+	case TunnelReconnecting:
+		return "reconnecting"
+	case TunnelFailed:
+		return "failed"
+	case TunnelClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// Terminal reports whether the tunnel can no longer change status.
+func (s TunnelStatus) Terminal() bool {
+	return s == TunnelFailed || s == TunnelClosed
+}
+
+// TunnelStatusObserver is called when a tunnel's status changes. prevID is
+// set when the tunnel comes back online after being re-bound, to the ID it
+// had before.
+type TunnelStatusObserver func(status TunnelStatus, prevID string)
+
+// Tracks the status of a tunnel and who to tell when it changes.
+type tunnelStatus struct {
+	mu       sync.Mutex
+	status   TunnelStatus
+	observer TunnelStatusObserver
+}
+
+func (s *tunnelStatus) get() TunnelStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Moves to status, unless the tunnel has already failed or been closed.
+// Coming back online is always reported, since the tunnel may have been
+// re-bound with a new ID or URL.
+func (s *tunnelStatus) set(status TunnelStatus, prevID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.Terminal() || (s.status == status && status != TunnelOnline) {
+		return
+	}
+	s.status = status
+	if s.observer != nil {
+		s.observer(status, prevID)
+	}
+}
+
+// Sets the observer, and calls it with the current status.
+func (s *tunnelStatus) observe(observer TunnelStatusObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observer = observer
+	observer(s.status, "")
+}
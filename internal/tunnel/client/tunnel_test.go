@@ -0,0 +1,22 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteBindConfigString(t *testing.T) {
+	cfg := &RemoteBindConfig{URL: "tcp://1.tcp.ngrok.io:12345"}
+	require.Equal(t, "1.tcp.ngrok.io:12345", cfg.String())
+
+	cfg = &RemoteBindConfig{URL: "https://example.ngrok.io"}
+	require.Equal(t, "example.ngrok.io", cfg.String())
+
+	cfg = &RemoteBindConfig{URL: "%%not a url"}
+	require.NotPanics(t, func() { _ = cfg.String() })
+	require.Equal(t, "%%not a url", cfg.String())
+
+	cfg = &RemoteBindConfig{Labels: map[string]string{"edge": "edghts_123", "app": "foo"}}
+	require.Equal(t, "app=foo,edge=edghts_123", cfg.String())
+}
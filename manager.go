@@ -0,0 +1,312 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+
+	"golang.ngrok.com/ngrok/config"
+)
+
+// SessionManager shares [Session]s between the tunnels of many ngrok
+// accounts, keyed by authtoken. Sessions are connected the first time a
+// tunnel is started with their authtoken, and closed once they have had no
+// tunnels for a while.
+type SessionManager interface {
+	// Listen starts a tunnel on the session for authtoken, connecting it
+	// first if needed. Closing the returned Tunnel releases the session.
+	Listen(ctx context.Context, authtoken string, cfg config.Tunnel) (Tunnel, error)
+
+	// Close closes every session, along with their tunnels.
+	Close() error
+}
+
+// ManagerOption customizes a [SessionManager].
+type ManagerOption func(*sessionManager)
+
+// WithManagerConnectOptions sets the options used to connect every session,
+// such as a dialer or logger. The authtoken is set by the manager.
+func WithManagerConnectOptions(opts ...ConnectOption) ManagerOption {
+	return func(m *sessionManager) {
+		m.connectOpts = append(m.connectOpts, opts...)
+	}
+}
+
+// WithIdleTimeout sets how long a session is kept open after its last tunnel
+// is closed. Defaults to one minute.
+func WithIdleTimeout(timeout time.Duration) ManagerOption {
+	return func(m *sessionManager) {
+		m.idleTimeout = timeout
+	}
+}
+
+// WithMaxConcurrentConnects limits how many sessions may be connecting or
+// reconnecting at once, so that a network outage doesn't cause every session
+// to reconnect at the same time. Defaults to 10. Zero means unlimited.
+func WithMaxConcurrentConnects(n int) ManagerOption {
+	return func(m *sessionManager) {
+		m.maxConnects = n
+	}
+}
+
+// NewSessionManager creates a [SessionManager].
+func NewSessionManager(opts ...ManagerOption) SessionManager {
+	m := &sessionManager{
+		idleTimeout: time.Minute,
+		maxConnects: 10,
+		connect:     Connect,
+		sessions:    make(map[string]*managedSession),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.limiter = newConnectLimiter(m.maxConnects)
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	return m
+}
+
+type sessionManager struct {
+	connectOpts []ConnectOption
+	idleTimeout time.Duration
+	maxConnects int
+	limiter     connectLimiter
+
+	// the context sessions are connected with, cancelled by Close
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// replaced in tests
+	connect func(ctx context.Context, opts ...ConnectOption) (Session, error)
+
+	mu       sync.Mutex
+	sessions map[string]*managedSession
+	closed   bool
+}
+
+type managedSession struct {
+	// closed once the session has connected or failed to
+	ready chan struct{}
+	sess  Session
+	err   error
+
+	// guarded by the manager's lock
+	refs int
+	idle *time.Timer
+}
+
+func (m *sessionManager) Listen(ctx context.Context, authtoken string, cfg config.Tunnel) (Tunnel, error) {
+	ms, err := m.acquire(ctx, authtoken)
+	if err != nil {
+		return nil, err
+	}
+
+	tun, err := ms.sess.Listen(ctx, cfg)
+	if err != nil {
+		m.release(authtoken, ms)
+		return nil, err
+	}
+
+	return &managedTunnel{
+		Tunnel:  tun,
+		release: func() { m.release(authtoken, ms) },
+	}, nil
+}
+
+// Returns the session for authtoken with an added reference, connecting it
+// if needed.
+func (m *sessionManager) acquire(ctx context.Context, authtoken string) (*managedSession, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, errors.New("session manager closed")
+	}
+	ms, ok := m.sessions[authtoken]
+	if !ok {
+		ms = &managedSession{ready: make(chan struct{})}
+		m.sessions[authtoken] = ms
+		go m.connectSession(authtoken, ms)
+	}
+	ms.refs++
+	if ms.idle != nil {
+		ms.idle.Stop()
+		ms.idle = nil
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-ms.ready:
+	case <-ctx.Done():
+		m.release(authtoken, ms)
+		return nil, ctx.Err()
+	}
+	if ms.err != nil {
+		m.release(authtoken, ms)
+		return nil, ms.err
+	}
+	return ms, nil
+}
+
+func (m *sessionManager) connectSession(authtoken string, ms *managedSession) {
+	opts := append([]ConnectOption{}, m.connectOpts...)
+	opts = append(opts,
+		WithAuthtoken(authtoken),
+		withConnectLimiter(m.limiter),
+		m.forgetOnStop(authtoken, ms),
+	)
+
+	// the session outlives any one caller, so is bound to the manager's
+	// context rather than theirs
+	sess, err := m.connect(m.ctx, opts...)
+
+	m.mu.Lock()
+	ms.sess, ms.err = sess, err
+	if err != nil {
+		if m.sessions[authtoken] == ms {
+			delete(m.sessions, authtoken)
+		}
+	} else if ms.refs == 0 {
+		// everyone waiting on it gave up
+		m.closeWhenIdle(authtoken, ms)
+	}
+	m.mu.Unlock()
+	close(ms.ready)
+}
+
+// Returns an option that forgets about the session once it stops for good,
+// so that the next tunnel for its authtoken connects a new one.
+func (m *sessionManager) forgetOnStop(authtoken string, ms *managedSession) ConnectOption {
+	var cfg connectConfig
+	for _, opt := range m.connectOpts {
+		opt(&cfg)
+	}
+	onDisconnect := cfg.DisconnectHandler
+
+	return func(cfg *connectConfig) {
+		cfg.DisconnectHandler = func(ctx context.Context, sess Session, err error) {
+			if err == nil {
+				m.forget(authtoken, ms)
+			}
+			if onDisconnect != nil {
+				onDisconnect(ctx, sess, err)
+			}
+		}
+	}
+}
+
+func (m *sessionManager) forget(authtoken string, ms *managedSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessions[authtoken] == ms {
+		delete(m.sessions, authtoken)
+	}
+}
+
+// Drops a reference to the session, closing it once it has been idle for
+// the idle timeout.
+func (m *sessionManager) release(authtoken string, ms *managedSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ms.refs--
+	if ms.refs > 0 || ms.sess == nil {
+		return
+	}
+	m.closeWhenIdle(authtoken, ms)
+}
+
+// Closes the session after the idle timeout, unless it's used again first.
+// Must be called with the manager's lock held.
+func (m *sessionManager) closeWhenIdle(authtoken string, ms *managedSession) {
+	ms.idle = time.AfterFunc(m.idleTimeout, func() {
+		m.mu.Lock()
+		if ms.refs > 0 || m.sessions[authtoken] != ms {
+			m.mu.Unlock()
+			return
+		}
+		delete(m.sessions, authtoken)
+		m.mu.Unlock()
+		_ = ms.sess.Close()
+	})
+}
+
+func (m *sessionManager) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	sessions := m.sessions
+	m.sessions = make(map[string]*managedSession)
+	for _, ms := range sessions {
+		if ms.idle != nil {
+			ms.idle.Stop()
+		}
+	}
+	m.mu.Unlock()
+
+	// cut short any connects that are still in progress
+	m.cancel()
+
+	var errs error
+	for _, ms := range sessions {
+		<-ms.ready
+		if ms.sess != nil {
+			errs = multierr.Append(errs, ms.sess.Close())
+		}
+	}
+	return errs
+}
+
+// A Tunnel that releases its session when closed.
+type managedTunnel struct {
+	Tunnel
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (t *managedTunnel) Close() error {
+	err := t.Tunnel.Close()
+	t.releaseOnce.Do(t.release)
+	return err
+}
+
+func (t *managedTunnel) CloseWithContext(ctx context.Context) error {
+	err := t.Tunnel.CloseWithContext(ctx)
+	t.releaseOnce.Do(t.release)
+	return err
+}
+
+// Limits the number of sessions connecting at once. A nil limiter doesn't
+// limit anything.
+type connectLimiter chan struct{}
+
+func newConnectLimiter(n int) connectLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(connectLimiter, n)
+}
+
+func (l connectLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l connectLimiter) release() {
+	if l != nil {
+		<-l
+	}
+}
+
+func withConnectLimiter(limiter connectLimiter) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.connectLimiter = limiter
+	}
+}
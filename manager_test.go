@@ -0,0 +1,214 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok/config"
+)
+
+// A Session which records its authtoken, and names its tunnels after it.
+type managedTestSession struct {
+	Session
+	authtoken string
+	closed    atomic.Bool
+}
+
+func (s *managedTestSession) Listen(ctx context.Context, cfg config.Tunnel) (Tunnel, error) {
+	return &idTunnel{id: s.authtoken}, nil
+}
+
+type idTunnel struct {
+	Tunnel
+	id string
+}
+
+func (t *idTunnel) ID() string {
+	return t.id
+}
+
+func (t *idTunnel) Close() error {
+	return nil
+}
+
+func (s *managedTestSession) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+type testConnector struct {
+	mu       sync.Mutex
+	sessions []*managedTestSession
+	fail     error
+	// if set, connects wait for their context to be done
+	hang bool
+}
+
+func (c *testConnector) connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
+	var cfg connectConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	c.mu.Lock()
+	hang := c.hang
+	c.mu.Unlock()
+	if hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fail != nil {
+		return nil, c.fail
+	}
+	sess := &managedTestSession{authtoken: cfg.Authtoken.PlainText()}
+	c.sessions = append(c.sessions, sess)
+	return sess, nil
+}
+
+func newTestManager(connector *testConnector, opts ...ManagerOption) *sessionManager {
+	m := NewSessionManager(opts...).(*sessionManager)
+	m.connect = connector.connect
+	return m
+}
+
+func TestSessionManagerShares(t *testing.T) {
+	connector := &testConnector{}
+	m := newTestManager(connector)
+	defer m.Close()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for _, token := range []string{"a", "a", "b", "a"} {
+		token := token
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tun, err := m.Listen(ctx, token, config.LabeledTunnel())
+			require.NoError(t, err)
+			require.Equal(t, token, tun.ID())
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, connector.sessions, 2)
+}
+
+func TestSessionManagerIdle(t *testing.T) {
+	connector := &testConnector{}
+	m := newTestManager(connector, WithIdleTimeout(50*time.Millisecond))
+	defer m.Close()
+	ctx := context.Background()
+
+	first, err := m.Listen(ctx, "a", config.LabeledTunnel())
+	require.NoError(t, err)
+	second, err := m.Listen(ctx, "a", config.LabeledTunnel())
+	require.NoError(t, err)
+	require.Len(t, connector.sessions, 1)
+	sess := connector.sessions[0]
+
+	// closing twice only releases once
+	require.NoError(t, first.Close())
+	require.NoError(t, first.Close())
+	time.Sleep(100 * time.Millisecond)
+	require.False(t, sess.closed.Load())
+
+	require.NoError(t, second.Close())
+	require.Eventually(t, sess.closed.Load, time.Second, 10*time.Millisecond)
+
+	// the next tunnel gets a new session
+	_, err = m.Listen(ctx, "a", config.LabeledTunnel())
+	require.NoError(t, err)
+	require.Len(t, connector.sessions, 2)
+}
+
+func TestSessionManagerReuseBeforeIdle(t *testing.T) {
+	connector := &testConnector{}
+	m := newTestManager(connector, WithIdleTimeout(50*time.Millisecond))
+	defer m.Close()
+	ctx := context.Background()
+
+	tun, err := m.Listen(ctx, "a", config.LabeledTunnel())
+	require.NoError(t, err)
+	require.NoError(t, tun.Close())
+
+	_, err = m.Listen(ctx, "a", config.LabeledTunnel())
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	require.Len(t, connector.sessions, 1)
+	require.False(t, connector.sessions[0].closed.Load())
+}
+
+func TestSessionManagerConnectFailure(t *testing.T) {
+	connector := &testConnector{fail: errors.New("bad authtoken")}
+	m := newTestManager(connector)
+	defer m.Close()
+	ctx := context.Background()
+
+	_, err := m.Listen(ctx, "a", config.LabeledTunnel())
+	require.ErrorIs(t, err, connector.fail)
+
+	// failures aren't cached
+	connector.fail = nil
+	_, err = m.Listen(ctx, "a", config.LabeledTunnel())
+	require.NoError(t, err)
+}
+
+func TestSessionManagerClose(t *testing.T) {
+	connector := &testConnector{}
+	m := newTestManager(connector)
+	ctx := context.Background()
+
+	for _, token := range []string{"a", "b"} {
+		_, err := m.Listen(ctx, token, config.LabeledTunnel())
+		require.NoError(t, err)
+	}
+	require.NoError(t, m.Close())
+	for _, sess := range connector.sessions {
+		require.True(t, sess.closed.Load())
+	}
+
+	_, err := m.Listen(ctx, "a", config.LabeledTunnel())
+	require.Error(t, err)
+}
+
+func TestSessionManagerClosePending(t *testing.T) {
+	connector := &testConnector{hang: true}
+	m := newTestManager(connector)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := m.Listen(ctx, "a", config.LabeledTunnel())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the connect still in progress doesn't hold up Close
+	closed := make(chan error, 1)
+	go func() { closed <- m.Close() }()
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Close waited on a pending connect")
+	}
+}
+
+func TestConnectLimiter(t *testing.T) {
+	require.NoError(t, newConnectLimiter(0).acquire(context.Background()))
+
+	limiter := newConnectLimiter(1)
+	require.NoError(t, limiter.acquire(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, limiter.acquire(ctx), context.DeadlineExceeded)
+
+	limiter.release()
+	require.NoError(t, limiter.acquire(context.Background()))
+}
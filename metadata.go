@@ -0,0 +1,131 @@
+package ngrok
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// How long connections over the previous connection are allowed to finish
+// after the session re-authenticates with new metadata, unless configured
+// with WithMetadataUpdateDrain.
+const metadataUpdateDrain = time.Minute
+
+// WithMetadataUpdateDrain sets how long connections over the previous
+// connection to the ngrok service are allowed to finish after
+// [Session].SetMetadata re-authenticates over a new one. Once it elapses,
+// the previous connection is closed along with any connections still using
+// it. Defaults to one minute.
+func WithMetadataUpdateDrain(drain time.Duration) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.MetadataUpdateDrain = drain
+	}
+}
+
+// MetadataSetter is implemented by both [Session] and [Tunnel].
+type MetadataSetter interface {
+	SetMetadata(ctx context.Context, metadata string) error
+}
+
+// MetadataGetter is implemented by both [Session] and [Tunnel].
+type MetadataGetter interface {
+	Metadata() string
+}
+
+// SetMetadataJSON encodes v as JSON and sets it as the metadata of target,
+// a [Session] or [Tunnel].
+func SetMetadataJSON(ctx context.Context, target MetadataSetter, v any) error {
+	metadata, err := json.Marshal(v)
+	if err != nil {
+		return errMetadataUpdate{err}
+	}
+	return target.SetMetadata(ctx, string(metadata))
+}
+
+// ParseMetadataJSON decodes the JSON metadata of source, a [Session] or
+// [Tunnel], into a T. Empty metadata decodes to the zero value.
+func ParseMetadataJSON[T any](source MetadataGetter) (T, error) {
+	var v T
+	metadata := source.Metadata()
+	if metadata == "" {
+		return v, nil
+	}
+	err := json.Unmarshal([]byte(metadata), &v)
+	return v, err
+}
+
+func (s *sessionImpl) Metadata() string {
+	metadata, _ := s.metadata.Load().(string)
+	return metadata
+}
+
+func (s *sessionImpl) SetMetadata(ctx context.Context, metadata string) error {
+	if err := s.Err(); err != nil {
+		return errMetadataUpdate{err}
+	}
+	s.metadata.Store(metadata)
+	// sent when the session first authenticates
+	if !s.isReady() {
+		return nil
+	}
+
+	migrator, ok := s.inner().Session.(interface {
+		Migrate(drain time.Duration) error
+	})
+	if !ok {
+		return errMetadataUpdate{errors.New("session can't re-authenticate")}
+	}
+
+	drain := s.metadataDrain
+	if drain <= 0 {
+		drain = metadataUpdateDrain
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- migrator.Migrate(drain)
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Migrate can't be interrupted, so it carries on in the
+		// background, and the new metadata is reported once it's done
+		return ctx.Err()
+	case err := <-done:
+		if err != nil {
+			// the metadata is still sent the next time the session reconnects
+			return errMetadataUpdate{err}
+		}
+		return nil
+	}
+}
+
+func (t *tunnelImpl) SetMetadata(ctx context.Context, metadata string) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- t.Tunnel.SetMetadata(metadata)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return errMetadataUpdate{err}
+		}
+		return nil
+	}
+}
+
+// SetMetadata waits for the tunnel to be bound before updating it.
+func (t *queuedTunnel) SetMetadata(ctx context.Context, metadata string) error {
+	select {
+	case <-t.bound:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if t.err != nil {
+		return errMetadataUpdate{t.err}
+	}
+	return t.tunnel.SetMetadata(ctx, metadata)
+}
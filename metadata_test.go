@@ -0,0 +1,122 @@
+package ngrok
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	tunnel_client "golang.ngrok.com/ngrok/internal/tunnel/client"
+)
+
+// An internal tunnel which records its metadata, failing updates with err.
+type metadataTunnel struct {
+	tunnel_client.Tunnel
+	metadata string
+	err      error
+}
+
+func (t *metadataTunnel) SetMetadata(metadata string) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.metadata = metadata
+	return nil
+}
+
+func (t *metadataTunnel) RemoteBindConfig() *tunnel_client.RemoteBindConfig {
+	return &tunnel_client.RemoteBindConfig{Metadata: t.metadata}
+}
+
+type buildInfo struct {
+	SHA  string `json:"sha"`
+	Role string `json:"role"`
+}
+
+func TestTunnelMetadataJSON(t *testing.T) {
+	tun := &tunnelImpl{Tunnel: &metadataTunnel{}}
+
+	info, err := ParseMetadataJSON[buildInfo](tun)
+	require.NoError(t, err)
+	require.Equal(t, buildInfo{}, info)
+
+	want := buildInfo{SHA: "abc123", Role: "leader"}
+	require.NoError(t, SetMetadataJSON(context.Background(), tun, want))
+	require.JSONEq(t, `{"sha":"abc123","role":"leader"}`, tun.Metadata())
+
+	info, err = ParseMetadataJSON[buildInfo](tun)
+	require.NoError(t, err)
+	require.Equal(t, want, info)
+
+	require.ErrorIs(t, SetMetadataJSON(context.Background(), tun, make(chan int)), errMetadataUpdate{})
+}
+
+func TestTunnelSetMetadataFailed(t *testing.T) {
+	tun := &tunnelImpl{Tunnel: &metadataTunnel{metadata: "old", err: testError}}
+	err := tun.SetMetadata(context.Background(), "new")
+	require.ErrorIs(t, err, errMetadataUpdate{})
+	require.ErrorIs(t, err, testError)
+	require.Equal(t, "old", tun.Metadata())
+}
+
+func TestSessionSetMetadataBeforeConnect(t *testing.T) {
+	sess := &sessionImpl{
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	sess.metadata.Store("old")
+	require.Equal(t, "old", sess.Metadata())
+
+	// sent once the session authenticates
+	require.NoError(t, sess.SetMetadata(context.Background(), "new"))
+	require.Equal(t, "new", sess.Metadata())
+}
+
+// An internal session which records the drain it's migrated with, blocking
+// until release is closed.
+type migratingSession struct {
+	tunnel_client.Session
+	drains  chan time.Duration
+	release chan struct{}
+}
+
+func (s *migratingSession) Migrate(drain time.Duration) error {
+	s.drains <- drain
+	<-s.release
+	return nil
+}
+
+func TestSessionSetMetadataDrain(t *testing.T) {
+	inner := &migratingSession{drains: make(chan time.Duration, 2), release: make(chan struct{})}
+	close(inner.release)
+
+	var cfg connectConfig
+	WithMetadataUpdateDrain(5 * time.Second)(&cfg)
+	sess := &sessionImpl{metadataDrain: cfg.MetadataUpdateDrain}
+	sess.setInner(&sessionInner{Session: inner})
+	require.NoError(t, sess.SetMetadata(context.Background(), "new"))
+	require.Equal(t, 5*time.Second, <-inner.drains)
+
+	sess = &sessionImpl{}
+	sess.setInner(&sessionInner{Session: inner})
+	require.NoError(t, sess.SetMetadata(context.Background(), "new"))
+	require.Equal(t, metadataUpdateDrain, <-inner.drains)
+}
+
+func TestSessionSetMetadataContext(t *testing.T) {
+	inner := &migratingSession{drains: make(chan time.Duration, 1), release: make(chan struct{})}
+	defer close(inner.release)
+
+	sess := &sessionImpl{}
+	sess.setInner(&sessionInner{Session: inner})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-inner.drains
+		cancel()
+	}()
+	require.ErrorIs(t, sess.SetMetadata(ctx, "new"), context.Canceled)
+	// the session still reports the new metadata once it re-authenticates
+	require.Equal(t, "new", sess.Metadata())
+}
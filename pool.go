@@ -0,0 +1,381 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+
+	"golang.ngrok.com/ngrok/config"
+)
+
+// SessionPool maintains several [Session]s with the ngrok service, so that
+// the loss of any one connection or region doesn't take its tunnels offline.
+// Each member reconnects on its own, just like a single [Session].
+type SessionPool interface {
+	// Listen starts a labeled tunnel on every member of the pool. The
+	// returned Tunnel accepts connections from all of them. Other kinds of
+	// tunnels can't be started on more than one session, and are rejected.
+	Listen(ctx context.Context, cfg config.Tunnel) (Tunnel, error)
+
+	// Sessions returns the members of the pool.
+	Sessions() []Session
+
+	// Health returns the number of members that are currently connected.
+	Health() PoolHealth
+
+	// Close closes every member of the pool, along with their tunnels.
+	Close() error
+}
+
+// PoolHealth is the aggregate state of the members of a [SessionPool].
+type PoolHealth struct {
+	// The number of members currently connected to the ngrok service.
+	Connected int
+	// The total number of members.
+	Total int
+}
+
+// Healthy reports whether at least one member is connected.
+func (h PoolHealth) Healthy() bool {
+	return h.Connected > 0
+}
+
+// ConnectPool connects a [SessionPool] of size sessions, each configured with
+// opts. Like [Connect], it blocks until every member has either connected or
+// failed to. As long as one of them connected, the pool is returned, and the
+// members that failed keep trying to connect in the background, as with
+// [WithBackgroundConnect]. If every member fails, the errors are returned.
+func ConnectPool(ctx context.Context, size int, opts ...ConnectOption) (SessionPool, error) {
+	return ConnectPoolRegions(ctx, make([]string, size), opts...)
+}
+
+// ConnectPoolRegions connects a [SessionPool] with one member in each of the
+// given regions, each configured with opts. An empty region lets ngrok pick
+// the fastest one, as with [WithRegion]. Members that fail to connect are
+// handled as described for [ConnectPool].
+func ConnectPoolRegions(ctx context.Context, regions []string, opts ...ConnectOption) (SessionPool, error) {
+	return connectPool(ctx, regions, opts, Connect)
+}
+
+func connectPool(ctx context.Context, regions []string, opts []ConnectOption, connect func(context.Context, ...ConnectOption) (Session, error)) (SessionPool, error) {
+	if len(regions) == 0 {
+		return nil, errors.New("a session pool needs at least one member")
+	}
+
+	pool := &sessionPool{
+		members: make([]*poolMember, len(regions)),
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   error
+		failed []int
+	)
+	memberOpts := make([][]ConnectOption, len(regions))
+	for i, region := range regions {
+		member := new(poolMember)
+		pool.members[i] = member
+
+		memberOpts[i] = append([]ConnectOption{}, opts...)
+		if region != "" {
+			memberOpts[i] = append(memberOpts[i], WithRegion(region))
+		}
+		memberOpts[i] = append(memberOpts[i], member.trackHealth(opts))
+
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sess, err := connect(ctx, memberOpts[i]...)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = multierr.Append(errs, err)
+				failed = append(failed, i)
+				return
+			}
+			member.sess = sess
+		}()
+	}
+	wg.Wait()
+
+	if len(failed) == len(regions) {
+		return nil, errs
+	}
+
+	// Keep trying the members that failed. Tunnels started on the pool are
+	// queued on them until they connect.
+	for _, i := range failed {
+		sess, err := connect(ctx, append(memberOpts[i], WithBackgroundConnect())...)
+		if err != nil {
+			_ = pool.Close()
+			return nil, err
+		}
+		pool.members[i].sess = sess
+	}
+
+	return pool, nil
+}
+
+type sessionPool struct {
+	members []*poolMember
+}
+
+type poolMember struct {
+	sess      Session
+	connected atomic.Bool
+}
+
+// Returns an option that keeps track of whether the member is connected,
+// while still calling the handlers configured by opts.
+func (m *poolMember) trackHealth(opts []ConnectOption) ConnectOption {
+	var cfg connectConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	onConnect, onDisconnect := cfg.ConnectHandler, cfg.DisconnectHandler
+
+	return func(cfg *connectConfig) {
+		cfg.ConnectHandler = func(ctx context.Context, sess Session) {
+			m.connected.Store(true)
+			if onConnect != nil {
+				onConnect(ctx, sess)
+			}
+		}
+		cfg.DisconnectHandler = func(ctx context.Context, sess Session, err error) {
+			m.connected.Store(false)
+			if onDisconnect != nil {
+				onDisconnect(ctx, sess, err)
+			}
+		}
+	}
+}
+
+func (p *sessionPool) Listen(ctx context.Context, cfg config.Tunnel) (Tunnel, error) {
+	tunnelCfg, ok := cfg.(tunnelConfigPrivate)
+	if !ok {
+		return nil, errors.New("invalid tunnel config")
+	}
+	if tunnelCfg.Proto() != "" {
+		return nil, errListen{errors.New("only labeled tunnels can be started on a session pool")}
+	}
+
+	tunnels := make([]Tunnel, 0, len(p.members))
+	for _, member := range p.members {
+		tun, err := member.sess.Listen(ctx, cfg)
+		if err != nil {
+			for _, started := range tunnels {
+				_ = started.Close()
+			}
+			return nil, err
+		}
+		tunnels = append(tunnels, tun)
+	}
+
+	return newPoolTunnel(tunnels), nil
+}
+
+func (p *sessionPool) Sessions() []Session {
+	sessions := make([]Session, 0, len(p.members))
+	for _, member := range p.members {
+		if member.sess != nil {
+			sessions = append(sessions, member.sess)
+		}
+	}
+	return sessions
+}
+
+func (p *sessionPool) Health() PoolHealth {
+	health := PoolHealth{Total: len(p.members)}
+	for _, member := range p.members {
+		if member.connected.Load() {
+			health.Connected++
+		}
+	}
+	return health
+}
+
+func (p *sessionPool) Close() error {
+	var errs error
+	for _, sess := range p.Sessions() {
+		errs = multierr.Append(errs, sess.Close())
+	}
+	return errs
+}
+
+// A Tunnel that accepts connections from the same tunnel started on each
+// member of a pool.
+type poolTunnel struct {
+	tunnels []Tunnel
+
+	// started on the first call to Accept, so that it doesn't compete with
+	// servers started by the tunnel config
+	start sync.Once
+	conns chan net.Conn
+	// closed once every member tunnel has stopped accepting
+	done    chan struct{}
+	lastErr error
+
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	status *statusWatchers
+}
+
+func newPoolTunnel(tunnels []Tunnel) *poolTunnel {
+	t := &poolTunnel{
+		tunnels: tunnels,
+		conns:   make(chan net.Conn),
+		done:    make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	t.status = newStatusWatchers(t.Status())
+	for _, tun := range tunnels {
+		changes := tun.StatusChanges()
+		go func() {
+			for range changes {
+				t.status.set(t.Status())
+			}
+			t.status.set(t.Status())
+		}()
+	}
+	return t
+}
+
+func (t *poolTunnel) acceptAll() {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, tun := range t.tunnels {
+		tun := tun
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				conn, err := tun.Accept()
+				if err != nil {
+					mu.Lock()
+					t.lastErr = err
+					mu.Unlock()
+					return
+				}
+				select {
+				case t.conns <- conn:
+				case <-t.closed:
+					_ = conn.Close()
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(t.done)
+	}()
+}
+
+func (t *poolTunnel) Accept() (net.Conn, error) {
+	t.start.Do(t.acceptAll)
+	select {
+	case conn := <-t.conns:
+		return conn, nil
+	case <-t.closed:
+		return nil, errAcceptFailed{Inner: net.ErrClosed}
+	case <-t.done:
+		return nil, t.lastErr
+	}
+}
+
+func (t *poolTunnel) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	return t.CloseWithContext(ctx)
+}
+
+func (t *poolTunnel) CloseWithContext(ctx context.Context) error {
+	var errs error
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		for _, tun := range t.tunnels {
+			errs = multierr.Append(errs, tun.CloseWithContext(ctx))
+		}
+	})
+	return errs
+}
+
+// The remaining methods describe the tunnel on the first member, since
+// they're the same on every member apart from the ID and Session.
+
+// Status returns the best status of any member, since the pool receives
+// connections as long as one of them does.
+func (t *poolTunnel) Status() TunnelStatus {
+	// from best to worst
+	rank := []TunnelStatus{TunnelOnline, TunnelReconnecting, TunnelBinding, TunnelClosed, TunnelFailed}
+	best := len(rank) - 1
+	for _, tun := range t.tunnels {
+		status := tun.Status()
+		for i := 0; i < best; i++ {
+			if rank[i] == status {
+				best = i
+				break
+			}
+		}
+	}
+	return rank[best]
+}
+
+func (t *poolTunnel) StatusChanges() <-chan TunnelStatus {
+	return t.status.watch()
+}
+
+// SetMetadata updates the metadata of every member.
+func (t *poolTunnel) SetMetadata(ctx context.Context, metadata string) error {
+	var errs error
+	for _, tun := range t.tunnels {
+		errs = multierr.Append(errs, tun.SetMetadata(ctx, metadata))
+	}
+	return errs
+}
+
+func (t *poolTunnel) Addr() net.Addr {
+	return t.tunnels[0].Addr()
+}
+
+func (t *poolTunnel) EndpointConfig() EndpointConfig {
+	return t.tunnels[0].EndpointConfig()
+}
+
+func (t *poolTunnel) ForwardsTo() string {
+	return t.tunnels[0].ForwardsTo()
+}
+
+func (t *poolTunnel) ID() string {
+	return t.tunnels[0].ID()
+}
+
+func (t *poolTunnel) Labels() map[string]string {
+	return t.tunnels[0].Labels()
+}
+
+func (t *poolTunnel) Metadata() string {
+	return t.tunnels[0].Metadata()
+}
+
+func (t *poolTunnel) Proto() string {
+	return t.tunnels[0].Proto()
+}
+
+func (t *poolTunnel) Session() Session {
+	return t.tunnels[0].Session()
+}
+
+func (t *poolTunnel) URL() string {
+	return t.tunnels[0].URL()
+}
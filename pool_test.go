@@ -0,0 +1,176 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A Tunnel that hands out connections sent on its conns channel.
+type chanTunnel struct {
+	Tunnel
+	id     string
+	conns  chan net.Conn
+	closed chan struct{}
+	status *statusWatchers
+}
+
+func newChanTunnel(id string) *chanTunnel {
+	return &chanTunnel{
+		id:     id,
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+		status: newStatusWatchers(TunnelOnline),
+	}
+}
+
+func (t *chanTunnel) Accept() (net.Conn, error) {
+	select {
+	case conn := <-t.conns:
+		return conn, nil
+	case <-t.closed:
+		return nil, errAcceptFailed{Inner: errors.New("tunnel closed")}
+	}
+}
+
+func (t *chanTunnel) CloseWithContext(context.Context) error {
+	close(t.closed)
+	t.status.set(TunnelClosed)
+	return nil
+}
+
+func (t *chanTunnel) Status() TunnelStatus {
+	return t.status.get()
+}
+
+func (t *chanTunnel) StatusChanges() <-chan TunnelStatus {
+	return t.status.watch()
+}
+
+func (t *chanTunnel) ID() string {
+	return t.id
+}
+
+func TestPoolTunnel(t *testing.T) {
+	first, second := newChanTunnel("first"), newChanTunnel("second")
+	pooled := newPoolTunnel([]Tunnel{first, second})
+	require.Equal(t, "first", pooled.ID())
+
+	for _, member := range []*chanTunnel{first, second, first} {
+		local, remote := net.Pipe()
+		defer remote.Close()
+		go func(member *chanTunnel) { member.conns <- local }(member)
+
+		conn, err := pooled.Accept()
+		require.NoError(t, err)
+		require.Equal(t, local, conn)
+	}
+
+	// losing one member doesn't stop the others
+	require.NoError(t, first.CloseWithContext(context.Background()))
+	local, remote := net.Pipe()
+	defer remote.Close()
+	go func() { second.conns <- local }()
+	conn, err := pooled.Accept()
+	require.NoError(t, err)
+	require.Equal(t, local, conn)
+
+	// once every member is gone, neither is the pool
+	require.NoError(t, second.CloseWithContext(context.Background()))
+	_, err = pooled.Accept()
+	require.ErrorIs(t, err, errAcceptFailed{})
+}
+
+func TestPoolTunnelClose(t *testing.T) {
+	first, second := newChanTunnel("first"), newChanTunnel("second")
+	pooled := newPoolTunnel([]Tunnel{first, second})
+
+	accepted := make(chan error)
+	go func() {
+		_, err := pooled.Accept()
+		accepted <- err
+	}()
+
+	require.NoError(t, pooled.Close())
+	select {
+	case err := <-accepted:
+		require.ErrorIs(t, err, net.ErrClosed)
+	case <-time.After(time.Second):
+		t.Fatal("accept didn't return after close")
+	}
+
+	for _, member := range []*chanTunnel{first, second} {
+		select {
+		case <-member.closed:
+		default:
+			t.Fatalf("member %s wasn't closed", member.id)
+		}
+	}
+}
+
+func TestPoolHealth(t *testing.T) {
+	var userConnects, userDisconnects int
+	opts := []ConnectOption{
+		WithConnectHandler(func(ctx context.Context, sess Session) { userConnects++ }),
+		WithDisconnectHandler(func(ctx context.Context, sess Session, err error) { userDisconnects++ }),
+	}
+
+	members := []*poolMember{new(poolMember), new(poolMember)}
+	pool := &sessionPool{members: members}
+	require.Equal(t, PoolHealth{Connected: 0, Total: 2}, pool.Health())
+	require.False(t, pool.Health().Healthy())
+
+	var cfgs [2]connectConfig
+	for i, member := range members {
+		for _, opt := range append(opts, member.trackHealth(opts)) {
+			opt(&cfgs[i])
+		}
+	}
+
+	cfgs[0].ConnectHandler(context.Background(), nil)
+	cfgs[1].ConnectHandler(context.Background(), nil)
+	require.Equal(t, PoolHealth{Connected: 2, Total: 2}, pool.Health())
+
+	cfgs[1].DisconnectHandler(context.Background(), nil, errors.New("gone"))
+	require.Equal(t, PoolHealth{Connected: 1, Total: 2}, pool.Health())
+	require.True(t, pool.Health().Healthy())
+
+	require.Equal(t, 2, userConnects)
+	require.Equal(t, 1, userDisconnects)
+}
+
+// A Session returned by a fake connect function.
+type poolTestSession struct {
+	Session
+	server     string
+	background bool
+}
+
+// Connects every region except "down", unless connecting in the background.
+func poolTestConnect(ctx context.Context, opts ...ConnectOption) (Session, error) {
+	var cfg connectConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.ServerAddr == "tunnel.down.ngrok.com:443" && !cfg.BackgroundConnect {
+		return nil, testError
+	}
+	return &poolTestSession{server: cfg.ServerAddr, background: cfg.BackgroundConnect}, nil
+}
+
+func TestConnectPoolPartial(t *testing.T) {
+	pool, err := connectPool(context.Background(), []string{"us", "down"}, nil, poolTestConnect)
+	require.NoError(t, err)
+
+	sessions := pool.Sessions()
+	require.Len(t, sessions, 2)
+	require.Equal(t, &poolTestSession{server: "tunnel.us.ngrok.com:443"}, sessions[0])
+	require.Equal(t, &poolTestSession{server: "tunnel.down.ngrok.com:443", background: true}, sessions[1])
+
+	_, err = connectPool(context.Background(), []string{"down", "down"}, nil, poolTestConnect)
+	require.ErrorIs(t, err, testError)
+}
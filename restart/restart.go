@@ -0,0 +1,291 @@
+// Package restart implements zero-downtime restarts for applications
+// embedding ngrok, driven by the Restart command in the ngrok dashboard or
+// API.
+//
+// On Restart, the running process starts a new copy of itself and waits for
+// it to connect and start its tunnels. Only then does the old process drain
+// and exit, so labeled tunnels and endpoints that allow pooling keep serving
+// traffic throughout. The new process signals readiness over a pipe it
+// inherits from the old one, passing the tunnels it started:
+//
+//	sess, err := ngrok.Connect(ctx,
+//		ngrok.WithRestartCommandHandler(restart.Handler()),
+//	)
+//	// ...
+//	tun, err := sess.Listen(ctx, config.LabeledTunnel(...))
+//	// ...
+//	_ = restart.Ready(tun)
+//
+// Every process calls [Ready] the same way, so the old process knows which
+// labeled tunnels it serves and refuses to hand over to a new process that
+// didn't start all of them.
+package restart
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.ngrok.com/ngrok"
+)
+
+// The environment variable holding the file descriptor of the pipe the new
+// process uses to signal readiness.
+const readyFDEnv = "NGROK_RESTART_READY_FD"
+
+// The labels of the tunnels this process passed to Ready, which a new
+// process must also start before this one hands over to it.
+var served struct {
+	mu     sync.Mutex
+	labels []map[string]string
+}
+
+// The message the new process writes to the ready pipe, followed by a
+// newline.
+type readyMsg struct {
+	Labels []map[string]string `json:"labels"`
+}
+
+// Option customizes the restart [Handler].
+type Option func(*restarter)
+
+// WithCommand sets the command used to start the new process. Defaults to
+// the running executable with the same arguments.
+func WithCommand(path string, args ...string) Option {
+	return func(r *restarter) {
+		r.path = path
+		r.args = args
+	}
+}
+
+// WithDrain configures a function which is called once the new process is
+// ready, before this one exits. Use it to finish in-flight requests, for
+// example with [net/http.Server.Shutdown]. The [ngrok.Session] is closed
+// after it returns.
+func WithDrain(drain func(ctx context.Context) error) Option {
+	return func(r *restarter) {
+		r.drain = drain
+	}
+}
+
+// WithDrainTimeout bounds how long draining may take. Defaults to 30 seconds.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(r *restarter) {
+		r.drainTimeout = timeout
+	}
+}
+
+// WithExit sets the function used to exit the old process. Defaults to
+// [os.Exit].
+func WithExit(exit func(code int)) Option {
+	return func(r *restarter) {
+		r.exit = exit
+	}
+}
+
+type restarter struct {
+	path         string
+	args         []string
+	drain        func(ctx context.Context) error
+	drainTimeout time.Duration
+	exit         func(code int)
+}
+
+// Handler returns a handler for [ngrok.WithRestartCommandHandler] which
+// starts a new process and waits for it to call [Ready].
+//
+// If the new process fails to start, doesn't start every labeled tunnel this
+// one passed to [Ready], or doesn't become ready before the handler's
+// context is done, it is killed, the error is reported to the
+// dashboard or API, and this process carries on. Otherwise this process
+// drains, closes its session, and exits once the response has been sent.
+func Handler(opts ...Option) ngrok.RestartCommandHandler {
+	r := &restarter{
+		drainTimeout: 30 * time.Second,
+		exit:         os.Exit,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return func(ctx context.Context, sess ngrok.Session, _ ngrok.RestartRequest) ngrok.CommandResult {
+		if err := r.start(ctx); err != nil {
+			return ngrok.CommandResult{Err: err}
+		}
+		return ngrok.CommandResult{
+			KeepSession: true,
+			AfterResponse: func() {
+				r.shutdown(sess)
+			},
+		}
+	}
+}
+
+// Starts the new process and waits for it to become ready.
+func (r *restarter) start(ctx context.Context) error {
+	if runtime.GOOS == "windows" {
+		return errors.New("restarting is not supported on windows")
+	}
+
+	path, args := r.path, r.args
+	if path == "" {
+		var err error
+		if path, err = os.Executable(); err != nil {
+			return err
+		}
+		args = os.Args[1:]
+	}
+
+	ready, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{w}
+	// ExtraFiles start at file descriptor 3
+	cmd.Env = append(os.Environ(), readyFDEnv+"=3")
+
+	err = cmd.Start()
+	// only the new process should hold the write end, so that reads fail if
+	// it exits without becoming ready
+	w.Close()
+	if err != nil {
+		return fmt.Errorf("starting new process: %w", err)
+	}
+	go func() { _ = cmd.Wait() }()
+
+	done := make(chan error, 1)
+	go func() {
+		// the new process may pass the pipe on to its own children, so read
+		// up to the newline rather than until it's closed
+		line, err := bufio.NewReader(ready).ReadBytes('\n')
+		if err != nil {
+			done <- err
+			return
+		}
+		var msg readyMsg
+		if err := json.Unmarshal(line, &msg); err != nil {
+			done <- fmt.Errorf("invalid ready message: %w", err)
+			return
+		}
+		done <- checkLabels(msg.Labels)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		if errors.Is(err, io.EOF) {
+			return errors.New("new process exited before becoming ready")
+		}
+		return fmt.Errorf("waiting for new process: %w", err)
+	}
+	return nil
+}
+
+// Checks that the new process started every labeled tunnel this one serves.
+func checkLabels(started []map[string]string) error {
+	served.mu.Lock()
+	defer served.mu.Unlock()
+
+	have := make(map[string]struct{}, len(started))
+	for _, labels := range started {
+		have[labelsKey(labels)] = struct{}{}
+	}
+	for _, labels := range served.labels {
+		key := labelsKey(labels)
+		if _, ok := have[key]; !ok {
+			return fmt.Errorf("new process didn't start the tunnel labeled %s", key)
+		}
+	}
+	return nil
+}
+
+// A canonical form of a set of labels.
+func labelsKey(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Drains and exits the old process.
+func (r *restarter) shutdown(sess ngrok.Session) {
+	if r.drain != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), r.drainTimeout)
+		_ = r.drain(ctx)
+		cancel()
+	}
+	if sess != nil {
+		_ = sess.Close()
+	}
+	r.exit(0)
+}
+
+// Ready tells the process that started this one via [Handler] that it has
+// connected and started tunnels, so the old process can exit. Call it once
+// all tunnels are listening, passing them in.
+//
+// The labeled tunnels passed in are also remembered, and a later restart of
+// this process only succeeds if the new process starts them all too. Ready
+// should be called even if this process wasn't started by a restart, for
+// this reason. Tunnels that aren't labeled are not checked.
+func Ready(tunnels ...ngrok.Tunnel) error {
+	var labels []map[string]string
+	for _, tun := range tunnels {
+		if l := tun.Labels(); len(l) > 0 {
+			labels = append(labels, l)
+		}
+	}
+	return ready(labels)
+}
+
+func ready(labels []map[string]string) error {
+	served.mu.Lock()
+	served.labels = labels
+	served.mu.Unlock()
+
+	fd, ok := os.LookupEnv(readyFDEnv)
+	if !ok {
+		return nil
+	}
+	// don't pass the pipe on to future restarts or other children
+	_ = os.Unsetenv(readyFDEnv)
+
+	var n uintptr
+	if _, err := fmt.Sscan(fd, &n); err != nil {
+		return fmt.Errorf("invalid %s: %w", readyFDEnv, err)
+	}
+	f := os.NewFile(n, "restart-ready")
+	if f == nil {
+		return fmt.Errorf("invalid %s: %s", readyFDEnv, fd)
+	}
+	defer f.Close()
+
+	msg, err := json.Marshal(readyMsg{Labels: labels})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(msg, '\n'))
+	return err
+}
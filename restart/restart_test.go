@@ -0,0 +1,127 @@
+package restart
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok"
+)
+
+const helperEnv = "NGROK_RESTART_TEST_HELPER"
+
+var testLabels = []map[string]string{{"edge": "edghts_123", "region": "us"}}
+
+// Not a real test: runs as the new process started by the restart handler.
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv(helperEnv) {
+	case "ready":
+		if err := Ready(); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case "labeled":
+		if err := ready(testLabels); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case "exit":
+		os.Exit(0)
+	case "hang":
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	}
+}
+
+func helperHandler(t *testing.T, mode string, opts ...Option) ngrok.RestartCommandHandler {
+	if runtime.GOOS == "windows" {
+		t.Skip("restarting is not supported on windows")
+	}
+	t.Setenv(helperEnv, mode)
+	return Handler(append([]Option{
+		WithCommand(os.Args[0], "-test.run=^TestHelperProcess$"),
+	}, opts...)...)
+}
+
+func TestRestart(t *testing.T) {
+	exited := -1
+	drained := false
+	handler := helperHandler(t, "ready",
+		WithDrain(func(ctx context.Context) error {
+			drained = true
+			return nil
+		}),
+		WithExit(func(code int) { exited = code }),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result := handler(ctx, nil, ngrok.RestartRequest{})
+	require.NoError(t, result.Err)
+	require.True(t, result.KeepSession)
+	require.False(t, drained, "must not drain before responding")
+	require.Equal(t, -1, exited)
+
+	result.AfterResponse()
+	require.True(t, drained)
+	require.Equal(t, 0, exited)
+}
+
+// Marks this process as serving tunnels with labels, as if it had passed them
+// to Ready.
+func serveLabels(t *testing.T, labels []map[string]string) {
+	require.NoError(t, ready(labels))
+	t.Cleanup(func() { _ = ready(nil) })
+}
+
+func TestRestartSameTunnels(t *testing.T) {
+	serveLabels(t, []map[string]string{{"region": "us", "edge": "edghts_123"}})
+	handler := helperHandler(t, "labeled", WithExit(func(code int) {}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result := handler(ctx, nil, ngrok.RestartRequest{})
+	require.NoError(t, result.Err)
+}
+
+func TestRestartMissingTunnel(t *testing.T) {
+	serveLabels(t, testLabels)
+	handler := helperHandler(t, "ready", WithExit(func(code int) {
+		t.Fatal("must not exit")
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result := handler(ctx, nil, ngrok.RestartRequest{})
+	require.ErrorContains(t, result.Err, "edge=edghts_123,region=us")
+	require.Nil(t, result.AfterResponse)
+}
+
+func TestRestartNotReady(t *testing.T) {
+	handler := helperHandler(t, "exit", WithExit(func(code int) {
+		t.Fatal("must not exit")
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result := handler(ctx, nil, ngrok.RestartRequest{})
+	require.Error(t, result.Err)
+	require.Nil(t, result.AfterResponse)
+}
+
+func TestRestartTimeout(t *testing.T) {
+	handler := helperHandler(t, "hang")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	result := handler(ctx, nil, ngrok.RestartRequest{})
+	require.ErrorIs(t, result.Err, context.DeadlineExceeded)
+}
+
+func TestReadyWithoutRestart(t *testing.T) {
+	require.NoError(t, Ready())
+}
@@ -0,0 +1,103 @@
+package ngrok
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+
+	"github.com/inconshreveable/log15/v3"
+)
+
+// WithServerPin pins the public key of the ngrok server's certificate. The
+// session only connects if some certificate in the server's chain has a
+// public key matching one of the pins, in addition to the usual validation
+// against the CAs from [WithCA]. If that validation is skipped, only the
+// server's own certificate is checked against the pins. Pass several pins to
+// rotate keys without downtime. Calling WithServerPin again adds to the pins.
+//
+// Each pin is the SHA-256 hash of a certificate's DER-encoded
+// SubjectPublicKeyInfo, as computed by [ServerPin].
+func WithServerPin(spkiSHA256 ...[]byte) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.ServerPins = append(cfg.ServerPins, spkiSHA256...)
+	}
+}
+
+// WithServerPinReportOnly makes the pins set by [WithServerPin] log a warning
+// when the ngrok server's certificate doesn't match them, instead of failing
+// to connect. Use it to try out new pins safely.
+func WithServerPinReportOnly() ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.ServerPinReportOnly = true
+	}
+}
+
+// ServerPin returns the pin of cert's public key, for use with
+// [WithServerPin].
+func ServerPin(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return sum[:]
+}
+
+// Checks the certificates presented by the ngrok server against a set of
+// pins.
+type serverPins struct {
+	pins       [][]byte
+	reportOnly bool
+	logger     log15.Logger
+}
+
+func (p serverPins) matches(cert *x509.Certificate) bool {
+	pin := ServerPin(cert)
+	for _, want := range p.pins {
+		if bytes.Equal(pin, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p serverPins) verify(cs tls.ConnectionState) error {
+	chains := cs.VerifiedChains
+	if len(chains) == 0 && len(cs.PeerCertificates) > 0 {
+		// verification was skipped, so nothing ties the other presented
+		// certificates to the server: anyone can send a copy of a pinned
+		// one. Only the leaf, whose key was used in the handshake, counts.
+		chains = [][]*x509.Certificate{cs.PeerCertificates[:1]}
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if p.matches(cert) {
+				return nil
+			}
+		}
+	}
+
+	var err error = errServerPinMismatch{}
+	if len(cs.PeerCertificates) > 0 {
+		err = errServerPinMismatch{Pin: ServerPin(cs.PeerCertificates[0])}
+	}
+	if p.reportOnly {
+		p.logger.Warn("server certificate does not match pins, connecting anyway", "err", err)
+		return nil
+	}
+	return err
+}
+
+// Installs the pin check on tlsConfig, after any existing connection
+// verification.
+func (p serverPins) install(tlsConfig *tls.Config) {
+	if len(p.pins) == 0 {
+		return
+	}
+	next := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if next != nil {
+			if err := next(cs); err != nil {
+				return err
+			}
+		}
+		return p.verify(cs)
+	}
+}
@@ -0,0 +1,133 @@
+package ngrok
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/inconshreveable/log15/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a self-signed certificate for localhost.
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// Performs a TLS handshake with a server presenting cert, using a client
+// config which trusts it and has pins installed.
+func handshakeWithPins(t *testing.T, cert tls.Certificate, pins serverPins) error {
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	clientConfig := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	pins.install(clientConfig)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	clientConn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer clientConn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return tls.Client(clientConn, clientConfig).HandshakeContext(ctx)
+}
+
+func TestServerPin(t *testing.T) {
+	cert := selfSignedCert(t)
+	other := selfSignedCert(t)
+	logger := log15.New()
+	logger.SetHandler(log15.DiscardHandler())
+
+	// any matching pin will do
+	err := handshakeWithPins(t, cert, serverPins{
+		pins:   [][]byte{ServerPin(other.Leaf), ServerPin(cert.Leaf)},
+		logger: logger,
+	})
+	require.NoError(t, err)
+
+	err = handshakeWithPins(t, cert, serverPins{
+		pins:   [][]byte{ServerPin(other.Leaf)},
+		logger: logger,
+	})
+	require.ErrorIs(t, err, errServerPinMismatch{})
+	var mismatch errServerPinMismatch
+	require.ErrorAs(t, err, &mismatch)
+	require.Equal(t, ServerPin(cert.Leaf), mismatch.Pin)
+
+	err = handshakeWithPins(t, cert, serverPins{
+		pins:       [][]byte{ServerPin(other.Leaf)},
+		reportOnly: true,
+		logger:     logger,
+	})
+	require.NoError(t, err)
+
+	// without pins, nothing is checked
+	require.NoError(t, handshakeWithPins(t, cert, serverPins{logger: logger}))
+}
+
+func TestServerPinUnverified(t *testing.T) {
+	cert := selfSignedCert(t)
+	other := selfSignedCert(t)
+	pins := serverPins{pins: [][]byte{ServerPin(other.Leaf)}}
+
+	// without a verified chain, a pinned certificate sent after the leaf
+	// proves nothing
+	err := pins.verify(tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert.Leaf, other.Leaf},
+	})
+	require.ErrorIs(t, err, errServerPinMismatch{})
+
+	require.NoError(t, pins.verify(tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{other.Leaf, cert.Leaf},
+	}))
+
+	// a verified chain is checked in full
+	require.NoError(t, pins.verify(tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert.Leaf},
+		VerifiedChains:   [][]*x509.Certificate{{cert.Leaf, other.Leaf}},
+	}))
+}
+
+func TestServerPinOptions(t *testing.T) {
+	cfg := connectConfig{}
+	WithServerPin([]byte("a"))(&cfg)
+	WithServerPin([]byte("b"), []byte("c"))(&cfg)
+	require.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, cfg.ServerPins)
+	require.False(t, cfg.ServerPinReportOnly)
+	WithServerPinReportOnly()(&cfg)
+	require.True(t, cfg.ServerPinReportOnly)
+}
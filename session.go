@@ -14,12 +14,14 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
 
 	"github.com/inconshreveable/log15/v3"
 	"go.uber.org/multierr"
+	"golang.org/x/crypto/ssh"
 	"golang.org/x/net/proxy"
 
 	"golang.ngrok.com/ngrok/config"
@@ -35,7 +37,17 @@ import (
 //go:embed VERSION
 var libraryAgentVersion string
 
-type AgentVersionDeprecated proto.AgentVersionDeprecated
+// AgentVersionDeprecated is the warning sent by the ngrok service when this
+// version of the library is deprecated.
+type AgentVersionDeprecated struct {
+	// The minimum version that will be supported after NextDate.
+	NextMin string
+	// The date after which this version will no longer be supported. Zero if
+	// unknown.
+	NextDate time.Time
+	// Additional details from the ngrok service.
+	Msg string
+}
 
 func (avd *AgentVersionDeprecated) Error() string {
 	return (*proto.AgentVersionDeprecated)(avd).Error()
@@ -51,6 +63,44 @@ type Session interface {
 	// Warnings returns a list of warnings generated for the session on connect/auth
 	Warnings() []error
 
+	// Info returns information about the session and its connection to the
+	// ngrok service, as of the most recent (re)connect.
+	Info() SessionInfo
+
+	// ServerInfo queries the ngrok server the session is connected to.
+	ServerInfo(ctx context.Context) (ServerInfo, error)
+
+	// Ready blocks until the session first connects to the ngrok service,
+	// and returns an error if it gives up before then. It returns
+	// immediately unless the session was started with
+	// [WithBackgroundConnect].
+	Ready(ctx context.Context) error
+
+	// Done returns a channel that's closed once the session has ended for
+	// good, and will no longer reconnect.
+	Done() <-chan struct{}
+
+	// Err returns why the session ended, or nil if it hasn't: for example
+	// [ErrSessionClosed], [ErrSessionStopped], an error matching
+	// [ErrAuthFailed], or the error of the context passed to [Connect].
+	Err() error
+
+	// Wait blocks until the session has ended and returns its Err, or
+	// until ctx is done.
+	Wait(ctx context.Context) error
+
+	// Metadata returns the session's metadata, as set by [WithMetadata] or
+	// SetMetadata.
+	Metadata() string
+
+	// SetMetadata replaces the session's metadata. The session
+	// re-authenticates over a new connection to report it, while
+	// connections over the current one are allowed to finish for the
+	// duration set by [WithMetadataUpdateDrain]. If ctx is done first,
+	// SetMetadata returns its error, but the session carries on
+	// re-authenticating in the background.
+	SetMetadata(ctx context.Context, metadata string) error
+
 	// Close ends the ngrok session. All Tunnel objects created by Listen
 	// on this session will be closed.
 	Close() error
@@ -126,6 +176,8 @@ func generateUserAgent(cs []clientInfo) string {
 type connectConfig struct {
 	// Your ngrok Authtoken.
 	Authtoken proto.ObfuscatedString
+	// If set, the authtoken is resolved from this provider on every connect.
+	AuthtokenProvider AuthtokenProvider
 	// The address of the ngrok server to connect to.
 	// Defaults to `tunnel.ngrok.com:443`
 	ServerAddr string
@@ -133,6 +185,28 @@ type connectConfig struct {
 	TLSConfigCustomizer func(*tls.Config)
 	// The [x509.CertPool] used to authenticate the ngrok server certificate.
 	CAPool *x509.CertPool
+	// SPKI SHA-256 pins, one of which the server's certificate chain must
+	// match.
+	ServerPins [][]byte
+	// Log pin mismatches rather than failing the connection.
+	ServerPinReportOnly bool
+	// Returns the certificate to present to the ngrok server.
+	GetClientCertificate func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+	// Called on every dial, to load the CAs and customize the TLS config.
+	CAProvider        CAProvider
+	TLSConfigProvider TLSConfigProvider
+	// Resumes earlier TLS sessions when reconnecting.
+	ClientSessionCache tls.ClientSessionCache
+	// How long to dial the last good ingress address directly, rather than
+	// resolving ServerAddr. Zero disables the cache.
+	IngressAddrTTL time.Duration
+
+	// Establishes the connection the session runs over, in place of the
+	// Dialer.
+	// If set, takes precedence over the Dialer and ProxyURL settings.
+	Transport SessionTransport
+	// Run the session over the Transport without TLS.
+	TransportSkipTLS bool
 
 	// The [Dialer] used to establish the initial TCP connection to the ngrok
 	// server.
@@ -148,6 +222,9 @@ type connectConfig struct {
 	// Opaque metadata string to be associated with the session.
 	// Viewable from the ngrok dashboard or API.
 	Metadata string
+	// How long the previous connection is kept after SetMetadata
+	// re-authenticates the session.
+	MetadataUpdateDrain time.Duration
 
 	// Child client types and versions used to identify specific applications
 	// using this library to the ngrok service.
@@ -163,10 +240,20 @@ type connectConfig struct {
 	ConnectHandler    SessionConnectHandler
 	DisconnectHandler SessionDisconnectHandler
 	HeartbeatHandler  SessionHeartbeatHandler
+	WarningHandler    SessionWarningHandler
 
-	StopHandler    ServerCommandHandler
-	RestartHandler ServerCommandHandler
-	UpdateHandler  ServerCommandHandler
+	ExpiryWarningLead    time.Duration
+	ExpiryHandler        SessionExpiryHandler
+	PlannedReconnectLead time.Duration
+
+	// Whether Connect returns before the session is established.
+	BackgroundConnect bool
+
+	RebindHandler TunnelRebindHandler
+
+	StopHandler    StopCommandHandler
+	RestartHandler RestartCommandHandler
+	UpdateHandler  UpdateCommandHandler
 
 	remoteStopErr    *string
 	remoteRestartErr *string
@@ -174,6 +261,9 @@ type connectConfig struct {
 
 	// The logger for the session to use.
 	Logger log.Logger
+
+	// Limits how many sessions may connect at once.
+	connectLimiter connectLimiter
 }
 
 // WithMetdata configures the opaque, machine-readable metadata string for this
@@ -204,7 +294,8 @@ func WithClientInfo(clientType, version string, comments ...string) ConnectOptio
 
 // WithDialer configures the session to use the provided [Dialer] when
 // establishing a connection to the ngrok service. This option will cause
-// [WithProxyURL] to be ignored.
+// [WithProxyURL] to be ignored, and is itself ignored if
+// [WithSessionTransport] is used.
 func WithDialer(dialer Dialer) ConnectOption {
 	return func(cfg *connectConfig) {
 		cfg.Dialer = dialer
@@ -244,6 +335,17 @@ func WithAuthtokenFromEnv() ConnectOption {
 	return WithAuthtoken(os.Getenv("NGROK_AUTHTOKEN"))
 }
 
+// WithAuthtokenSource configures the session to authenticate with an
+// authtoken resolved from src. The source is consulted each time the session
+// connects or reconnects, so a rotated authtoken is picked up without
+// restarting the application. It takes precedence over [WithAuthtoken].
+func WithAuthtokenSource(src config.SecretSource) ConnectOption {
+	return WithAuthtokenProvider(func(ctx context.Context) (string, error) {
+		token, err := src.Secret(ctx)
+		return string(token), err
+	})
+}
+
 // WithRegion configures the session to connect to a specific ngrok region.
 // If unspecified, ngrok will connect to the fastest region, which is usually what you want.
 // The [full list of ngrok regions] can be found in the ngrok documentation.
@@ -381,9 +483,9 @@ func WithHeartbeatHandler(handler SessionHeartbeatHandler) ConnectOption {
 // Instead, either return an error or if you intend to Stop, spawn a goroutine
 // to asynchronously call [Session].Close or [os.Exit].
 func WithStopHandler(handler ServerCommandHandler) ConnectOption {
-	return func(cfg *connectConfig) {
-		cfg.StopHandler = handler
-	}
+	return WithStopCommandHandler(func(ctx context.Context, sess Session, _ StopRequest) CommandResult {
+		return CommandResult{Err: handler(ctx, sess)}
+	})
 }
 
 // WithRestartHandler configures a function which is called when the ngrok service
@@ -399,9 +501,9 @@ func WithStopHandler(handler ServerCommandHandler) ConnectOption {
 //
 // Instead, either spawn a goroutine to asynchronously restart, or return an error.
 func WithRestartHandler(handler ServerCommandHandler) ConnectOption {
-	return func(cfg *connectConfig) {
-		cfg.RestartHandler = handler
-	}
+	return WithRestartCommandHandler(func(ctx context.Context, sess Session, _ RestartRequest) CommandResult {
+		return CommandResult{Err: handler(ctx, sess)}
+	})
 }
 
 // WithUpdateHandler configures a function which is called when the ngrok service
@@ -419,9 +521,9 @@ func WithRestartHandler(handler ServerCommandHandler) ConnectOption {
 // Instead, spawn a goroutine to asynchronously handle the update process
 // or return an error if there is no newer version to update to.
 func WithUpdateHandler(handler ServerCommandHandler) ConnectOption {
-	return func(cfg *connectConfig) {
-		cfg.UpdateHandler = handler
-	}
+	return WithUpdateCommandHandler(func(ctx context.Context, sess Session, _ UpdateRequest) CommandResult {
+		return CommandResult{Err: handler(ctx, sess)}
+	})
 }
 
 // WithStopCommandDisabled specifies a user-friendly error message to be reported
@@ -470,8 +572,8 @@ func WithUpdateCommandDisabled(err string) ConnectOption {
 // retrying transient failures if they occur.
 //
 // Connect blocks until the session is successfully established or fails with
-// an error that will not be retried. Customize session connection behavior
-// with [ConnectOption] arguments.
+// an error that will not be retried, unless [WithBackgroundConnect] is used.
+// Customize session connection behavior with [ConnectOption] arguments.
 func Connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
 	logger := log15.New()
 	logger.SetHandler(log15.DiscardHandler())
@@ -485,23 +587,18 @@ func Connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
 		logger = toLog15(cfg.Logger)
 	}
 
-	if cfg.CAPool == nil {
-		cfg.CAPool = x509.NewCertPool()
-		cfg.CAPool.AppendCertsFromPEM(defaultCACert)
-	}
+	defaultCAs := x509.NewCertPool()
+	defaultCAs.AppendCertsFromPEM(defaultCACert)
 
 	if cfg.ServerAddr == "" {
 		cfg.ServerAddr = defaultServer
 	}
 
-	tlsConfig := &tls.Config{
-		RootCAs:    cfg.CAPool,
-		ServerName: strings.Split(cfg.ServerAddr, ":")[0],
-		MinVersion: tls.VersionTLS12,
-	}
-	if cfg.TLSConfigCustomizer != nil {
-		cfg.TLSConfigCustomizer(tlsConfig)
+	// kept across reconnects, so that they can resume the TLS session
+	if cfg.ClientSessionCache == nil {
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
 	}
+	ingress := cfg.ingressCache()
 
 	var dialer Dialer
 
@@ -529,11 +626,28 @@ func Connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
 		heartbeatConfig.Interval = cfg.HeartbeatInterval
 	}
 
-	session := new(sessionImpl)
+	// Ends with the session, stopping everything running on its behalf.
+	lifetime, endLifetime := context.WithCancel(ctx)
+
+	session := &sessionImpl{
+		ready:       make(chan struct{}),
+		done:        make(chan struct{}),
+		lifetime:    lifetime,
+		endLifetime: endLifetime,
+
+		handlerCtx:    ctx,
+		rebindHandler: cfg.RebindHandler,
+		metadataDrain: cfg.MetadataUpdateDrain,
+	}
+	if session.metadataDrain <= 0 {
+		session.metadataDrain = metadataUpdateDrain
+	}
+	session.metadata.Store(cfg.Metadata)
 
 	stateChanges := make(chan error, 32)
 
 	callbackHandler := remoteCallbackHandler{
+		ctx:            lifetime,
 		Logger:         logger,
 		sess:           session,
 		stopHandler:    cfg.StopHandler,
@@ -541,13 +655,69 @@ func Connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
 		updateHandler:  cfg.UpdateHandler,
 	}
 
+	// The most recently dialed connection, recorded for SessionInfo. The
+	// reconnect callback always runs after the dial it belongs to.
+	var dialedConn atomic.Pointer[connectionInfo]
+	// The TLS settings of the most recently dialed connection, which are
+	// advertised when authenticating.
+	var dialedTLS atomic.Pointer[dialTLS]
+
+	// Set while this session holds a slot of the connect limiter, from the
+	// start of a dial until authentication completes.
+	var limiterHeld atomic.Bool
+	releaseLimiter := func() {
+		if limiterHeld.Swap(false) {
+			cfg.connectLimiter.release()
+		}
+	}
+
 	rawDialer := func() (tunnel_client.RawSession, error) {
-		conn, err := dialer.DialContext(ctx, "tcp", cfg.ServerAddr)
+		if err := cfg.connectLimiter.acquire(lifetime); err != nil {
+			return nil, errSessionDial{cfg.ServerAddr, err}
+		}
+		limiterHeld.Store(true)
+
+		// re-evaluated on every dial, so that rotated CAs and certificates
+		// are picked up
+		var tlsSettings *dialTLS
+		if !cfg.skipsTLS() {
+			var err error
+			tlsSettings, err = cfg.dialTLS(lifetime, defaultCAs, logger)
+			if err != nil {
+				releaseLimiter()
+				return nil, errSessionDial{cfg.ServerAddr, err}
+			}
+		}
+
+		start := time.Now()
+		conn, err := cfg.dial(lifetime, dialer, ingress)
 		if err != nil {
+			releaseLimiter()
 			return nil, errSessionDial{cfg.ServerAddr, err}
 		}
+		dialed := time.Now()
+		ingressAddr := conn.RemoteAddr()
+
+		if tlsSettings != nil {
+			tlsConn := tls.Client(conn, tlsSettings.config)
+			// handshake now, so that certificate problems are reported as a
+			// failure to dial
+			if err := tlsConn.HandshakeContext(lifetime); err != nil {
+				_ = conn.Close()
+				releaseLimiter()
+				// the cached address may no longer belong to ngrok
+				ingress.forget()
+				return nil, errSessionDial{cfg.ServerAddr, err}
+			}
+			conn = tlsConn
+		}
+		ingress.remember(ingressAddr)
 
-		conn = tls.Client(conn, tlsConfig)
+		info := newConnectionInfo(cfg.ServerAddr, conn)
+		info.DialDuration = dialed.Sub(start)
+		info.HandshakeDuration = time.Since(dialed)
+		dialedConn.Store(&info)
+		dialedTLS.Store(tlsSettings)
 
 		sess := muxado.Client(conn, &muxado.Config{})
 		return tunnel_client.NewRawSession(logger, sess, heartbeatConfig, callbackHandler), nil
@@ -599,64 +769,126 @@ func Connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
 		UpdateUnsupportedError:  cfg.remoteUpdateErr,
 	}
 
-	reconnect := func(sess tunnel_client.Session) error {
-		resp, err := sess.Auth(auth)
+	expiry := new(expiryTimers)
+	warnings := new(seenWarnings)
+
+	reconnect := func(sess tunnel_client.Session) (func(), error) {
+		defer releaseLimiter()
+
+		// the settings for this attempt, leaving auth to carry the cookie
+		// between attempts
+		extra := auth
+		extra.Metadata = session.Metadata()
+		if tlsSettings := dialedTLS.Load(); tlsSettings != nil {
+			extra.MutualTLS = presentsClientCertificate(tlsSettings.config)
+			extra.CustomCAs = tlsSettings.customCAs
+		}
+
+		start := time.Now()
+		resp, err := authenticate(lifetime, sess, extra, cfg.AuthtokenProvider)
 		if err != nil {
-			remote := false
-			if resp.Error != "" {
-				err = errors.New(resp.Error)
-				remote = true
-			}
-			return errAuthFailed{remote, err}
+			return nil, err
 		}
 
-		if resp.Extra.DeprecationWarning != nil {
-			warning := resp.Extra.DeprecationWarning
-			vars := make([]any, 0, 3)
-			if warning.NextMin != "" {
-				vars = append(vars, "min_version", warning.NextMin)
-			}
-			if !warning.NextDate.IsZero() {
-				vars = append(vars, "deadline", warning.NextDate)
-			}
-			if warning.Msg != "" {
-				vars = append(vars, "extra", warning.Msg)
+		conn := *dialedConn.Load()
+		conn.AuthDuration = time.Since(start)
+
+		// everything describing the new connection waits until its tunnels
+		// are re-bound: a planned reconnect that fails to re-bind them goes
+		// back to the previous connection
+		return func() {
+			logger.Debug("session authenticated",
+				"dial", conn.DialDuration,
+				"handshake", conn.HandshakeDuration,
+				"auth", conn.AuthDuration,
+				"tls_resumed", conn.TLSResumed,
+			)
+
+			if resp.Extra.DeprecationWarning != nil {
+				warning := resp.Extra.DeprecationWarning
+				vars := make([]any, 0, 3)
+				if warning.NextMin != "" {
+					vars = append(vars, "min_version", warning.NextMin)
+				}
+				if !warning.NextDate.IsZero() {
+					vars = append(vars, "deadline", warning.NextDate)
+				}
+				if warning.Msg != "" {
+					vars = append(vars, "extra", warning.Msg)
+				}
+				logger.Warn(warning.Error(), vars...)
 			}
-			logger.Warn(warning.Error(), vars...)
-		}
 
-		session.setInner(&sessionInner{
-			Session:            sess,
-			Region:             resp.Extra.Region,
-			ProtoVersion:       resp.Version,
-			ServerVersion:      resp.Extra.Version,
-			ClientID:           resp.Extra.Region,
-			AccountName:        resp.Extra.AccountName,
-			PlanName:           resp.Extra.PlanName,
-			Banner:             resp.Extra.Banner,
-			SessionDuration:    resp.Extra.SessionDuration,
-			DeprecationWarning: resp.Extra.DeprecationWarning,
-		})
+			session.setInner(&sessionInner{
+				Session:            sess,
+				Region:             resp.Extra.Region,
+				ProtoVersion:       resp.Version,
+				ServerVersion:      resp.Extra.Version,
+				ClientID:           resp.ClientID,
+				AccountName:        resp.Extra.AccountName,
+				PlanName:           resp.Extra.PlanName,
+				Banner:             resp.Extra.Banner,
+				SessionDuration:    resp.Extra.SessionDuration,
+				DeprecationWarning: resp.Extra.DeprecationWarning,
+				Connection:         conn,
+			})
+
+			if cfg.WarningHandler != nil {
+				fresh := warnings.unseen(sessionWarnings(resp.Extra.DeprecationWarning, resp.Extra.Banner))
+				if len(fresh) > 0 {
+					go func() {
+						for _, warning := range fresh {
+							cfg.WarningHandler(ctx, session, warning)
+						}
+					}()
+				}
+			}
 
-		if cfg.HeartbeatHandler != nil {
-			go func() {
-				beats := session.Latency()
-				for {
-					select {
-					case <-ctx.Done():
-						return
-					case latency, ok := <-beats:
-						if !ok {
+			if cfg.HeartbeatHandler != nil {
+				go func() {
+					beats := session.Latency()
+					for {
+						select {
+						case <-lifetime.Done():
 							return
+						case latency, ok := <-beats:
+							if !ok {
+								return
+							}
+							cfg.HeartbeatHandler(ctx, session, latency)
 						}
-						cfg.HeartbeatHandler(ctx, session, latency)
 					}
+				}()
+			}
+
+			// replace the timers of the previous connection
+			expiry.stop()
+			if resp.Extra.SessionDuration > 0 {
+				deadline := time.Now().Add(time.Duration(resp.Extra.SessionDuration) * time.Second)
+				if cfg.ExpiryHandler != nil {
+					expiry.schedule(deadline, cfg.ExpiryWarningLead, func(remaining time.Duration) {
+						if lifetime.Err() == nil {
+							cfg.ExpiryHandler(ctx, session, remaining)
+						}
+					})
 				}
-			}()
-		}
+				if migrator, ok := sess.(interface {
+					Migrate(drain time.Duration) error
+				}); ok && cfg.PlannedReconnectLead > 0 {
+					expiry.schedule(deadline, cfg.PlannedReconnectLead, func(remaining time.Duration) {
+						if lifetime.Err() != nil {
+							return
+						}
+						logger.Info("session is about to expire, reconnecting", "remaining", remaining)
+						if err := migrator.Migrate(remaining); err != nil {
+							logger.Warn("planned reconnect failed", "err", err)
+						}
+					})
+				}
+			}
 
-		auth.Cookie = resp.Extra.Cookie
-		return nil
+			auth.Cookie = resp.Extra.Cookie
+		}, nil
 	}
 
 	sess := tunnel_client.NewReconnectingSession(logger, rawDialer, stateChanges, reconnect)
@@ -665,11 +897,19 @@ func Connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
 		Session: sess,
 	})
 
+	// the errors seen before the first successful connect
+	var connectErrs error
+	// the error since the last successful connect, if any
+	var lastErr error
+
 	// performs one "pump" of the session update channel
 	// returns true if there are more updates to handle
 	runSessionHandlers := func() (bool, error) {
 		select {
 		case <-ctx.Done():
+			session.stopping(ctx.Err())
+			session.markReady(multierr.Append(connectErrs, ctx.Err()))
+			expiry.stop()
 			if cfg.DisconnectHandler != nil {
 				cfg.DisconnectHandler(ctx, session, ctx.Err())
 				logger.Info("no more state changes")
@@ -680,6 +920,8 @@ func Connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
 		case err, ok := <-stateChanges:
 			switch {
 			case !ok: // session has given up on reconnecting
+				session.markReady(connectErrs)
+				expiry.stop()
 				if cfg.DisconnectHandler != nil {
 					logger.Info("no more state changes")
 					cfg.DisconnectHandler(ctx, session, nil)
@@ -687,11 +929,17 @@ func Connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
 				sess.Close()
 				return false, nil
 			case err != nil: // session encountered an error
+				lastErr = err
+				if !session.isReady() {
+					connectErrs = multierr.Append(connectErrs, err)
+				}
 				if cfg.DisconnectHandler != nil {
 					cfg.DisconnectHandler(ctx, session, err)
 				}
 				return true, err
 			case err == nil: // session connected successfully
+				lastErr = nil
+				session.markReady(nil)
 				if cfg.ConnectHandler != nil {
 					cfg.ConnectHandler(ctx, session)
 				}
@@ -702,6 +950,18 @@ func Connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
 		panic("inexhaustive case match when handling session state change")
 	}
 
+	// pumps the session update channel until the session ends
+	run := func() {
+		for again := true; again; again, _ = runSessionHandlers() {
+		}
+		session.finish(lastErr, connectErrs)
+	}
+
+	if cfg.BackgroundConnect {
+		go run()
+		return session, nil
+	}
+
 	var errs error
 	for again := true; again; {
 		var err error
@@ -713,20 +973,40 @@ func Connect(ctx context.Context, opts ...ConnectOption) (Session, error) {
 			errs = multierr.Append(errs, err)
 		case !again: // gave up trying to reconnect
 			errs = multierr.Append(errs, err)
+			session.finish(lastErr, connectErrs)
 			return nil, errs
 		}
 	}
 
-	go func() {
-		for again := true; again; again, _ = runSessionHandlers() {
-		}
-	}()
+	go run()
 
 	return session, nil
 }
 
 type sessionImpl struct {
 	raw unsafe.Pointer
+
+	// closed once the session first connects, or gives up trying
+	ready     chan struct{}
+	readyOnce sync.Once
+	readyErr  error
+
+	// closed once the session has ended for good
+	done        chan struct{}
+	err         error
+	stopOnce    sync.Once
+	stopErr     error
+	lifetime    context.Context
+	endLifetime context.CancelFunc
+
+	handlerCtx    context.Context
+	rebindHandler TunnelRebindHandler
+
+	// the metadata sent when (re)authenticating
+	metadata atomic.Value
+	// how long the previous connection is kept after re-authenticating with
+	// new metadata
+	metadataDrain time.Duration
 }
 
 type sessionInner struct {
@@ -741,6 +1021,7 @@ type sessionInner struct {
 	Banner             string
 	SessionDuration    int64
 	DeprecationWarning *proto.AgentVersionDeprecated
+	Connection         connectionInfo
 }
 
 func (s *sessionImpl) inner() *sessionInner {
@@ -756,35 +1037,43 @@ func (s *sessionImpl) setInner(raw *sessionInner) {
 }
 
 func (s *sessionImpl) Close() error {
-	return s.inner().Close()
+	return s.stop(ErrSessionClosed)
 }
 
 func (s *sessionImpl) Listen(ctx context.Context, cfg config.Tunnel) (Tunnel, error) {
-	var (
-		tunnel tunnel_client.Tunnel
-		err    error
-	)
-
-	tunnelCfg, ok := cfg.(tunnelConfigPrivate)
-	if !ok {
+	if _, ok := cfg.(tunnelConfigPrivate); !ok {
 		return nil, errors.New("invalid tunnel config")
 	}
 
-	extra := tunnelCfg.Extra()
-
-	if tunnelCfg.Proto() != "" {
-		tunnel, err = s.inner().Listen(tunnelCfg.Proto(), tunnelCfg.Opts(), extra, tunnelCfg.ForwardsTo())
-	} else {
-		tunnel, err = s.inner().ListenLabel(tunnelCfg.Labels(), extra.Metadata, tunnelCfg.ForwardsTo())
+	if validator, ok := cfg.(interface {
+		Validate() error
+	}); ok {
+		if err := validator.Validate(); err != nil {
+			return nil, errListen{err}
+		}
 	}
 
-	if err != nil {
-		return nil, errListen{err}
+	var termination *tls.Config
+	if termCfg, ok := cfg.(interface {
+		TLSTerminationConfig(ctx context.Context) (*tls.Config, error)
+	}); ok {
+		var err error
+		termination, err = termCfg.TLSTerminationConfig(ctx)
+		if err != nil {
+			return nil, errListen{err}
+		}
 	}
 
-	t := &tunnelImpl{
-		Sess:   s,
-		Tunnel: tunnel,
+	var t Tunnel
+	if s.isReady() {
+		bound, err := s.bind(ctx, cfg, termination)
+		if err != nil {
+			return nil, err
+		}
+		s.track(bound, bound, newStatusWatchers(TunnelOnline))
+		t = bound
+	} else {
+		t = newQueuedTunnel(s, cfg, termination)
 	}
 
 	if httpServerCfg, ok := cfg.(interface {
@@ -797,9 +1086,70 @@ func (s *sessionImpl) Listen(ctx context.Context, cfg config.Tunnel) (Tunnel, er
 		}
 	}
 
+	if sshServerCfg, ok := cfg.(interface {
+		SSHServer() (*ssh.ServerConfig, config.SSHConnHandler)
+	}); ok {
+		if srv, handler := sshServerCfg.SSHServer(); srv != nil {
+			go func() {
+				_ = serveSSH(t, srv, handler, handshakeTimeout)
+			}()
+		}
+	}
+
 	return t, nil
 }
 
+// How long resolving the secrets of a tunnel may take when it's re-bound.
+const secretResolveTimeout = 30 * time.Second
+
+// Starts the tunnel described by cfg on the connected session.
+func (s *sessionImpl) bind(ctx context.Context, cfg config.Tunnel, termination *tls.Config) (*tunnelImpl, error) {
+	var (
+		tunnel tunnel_client.Tunnel
+		err    error
+	)
+
+	tunnelCfg := cfg.(tunnelConfigPrivate)
+	extra := tunnelCfg.Extra()
+
+	if resolver, ok := cfg.(interface {
+		ResolveOpts(ctx context.Context) (any, error)
+	}); ok {
+		var opts any
+		opts, err = resolver.ResolveOpts(ctx)
+		if err != nil {
+			return nil, errListen{err}
+		}
+		if sources, ok := cfg.(interface {
+			HasSecretSources() bool
+			ResolveSecretsInto(ctx context.Context, opts any) (any, error)
+		}); ok && sources.HasSecretSources() {
+			tunnel, err = s.inner().ListenWithResolver(tunnelCfg.Proto(), opts, func(current any) (any, error) {
+				// a hung secret source mustn't hold up reconnecting
+				ctx, cancel := context.WithTimeout(s.lifetime, secretResolveTimeout)
+				defer cancel()
+				return sources.ResolveSecretsInto(ctx, current)
+			}, extra, tunnelCfg.ForwardsTo())
+		} else {
+			tunnel, err = s.inner().Listen(tunnelCfg.Proto(), opts, extra, tunnelCfg.ForwardsTo())
+		}
+	} else if tunnelCfg.Proto() != "" {
+		tunnel, err = s.inner().Listen(tunnelCfg.Proto(), tunnelCfg.Opts(), extra, tunnelCfg.ForwardsTo())
+	} else {
+		tunnel, err = s.inner().ListenLabel(tunnelCfg.Labels(), extra.Metadata, tunnelCfg.ForwardsTo())
+	}
+
+	if err != nil {
+		return nil, errListen{err}
+	}
+
+	return &tunnelImpl{
+		Sess:        s,
+		Tunnel:      tunnel,
+		termination: termination,
+	}, nil
+}
+
 func (s *sessionImpl) Warnings() []error {
 	deprecated := s.inner().DeprecationWarning
 	if deprecated != nil {
@@ -810,7 +1160,8 @@ func (s *sessionImpl) Warnings() []error {
 
 // The rest of the `sessionImpl` methods are non-public, but can be
 // interface-asserted if they're *really* needed. These are exempt from any
-// stability guarantees and subject to change without notice.
+// stability guarantees and subject to change without notice. Prefer
+// [Session].Info, which provides the same information.
 
 func (s *sessionImpl) ProtoVersion() string {
 	return s.inner().ProtoVersion
@@ -845,54 +1196,69 @@ func (s *sessionImpl) Latency() <-chan time.Duration {
 
 type remoteCallbackHandler struct {
 	log15.Logger
+	ctx            context.Context
 	sess           Session
-	stopHandler    ServerCommandHandler
-	restartHandler ServerCommandHandler
-	updateHandler  ServerCommandHandler
+	stopHandler    StopCommandHandler
+	restartHandler RestartCommandHandler
+	updateHandler  UpdateCommandHandler
+}
+
+// The context for handling a command, which is done after commandTimeout or
+// when the session closes.
+func (rc remoteCallbackHandler) commandContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(rc.ctx, commandTimeout)
 }
 
 func (rc remoteCallbackHandler) OnStop(_ *proto.Stop, respond tunnel_client.HandlerRespFunc) {
 	if rc.stopHandler != nil {
-		resp := new(proto.StopResp)
-		close := true
-		if err := rc.stopHandler(context.TODO(), rc.sess); err != nil {
-			close = false
-			resp.Error = err.Error()
-		}
+		ctx, cancel := rc.commandContext()
+		result := rc.stopHandler(ctx, rc.sess, StopRequest{})
+		cancel()
+		resp := &proto.StopResp{Error: errString(result.Err)}
 		if err := respond(resp); err != nil {
 			rc.Warn("error responding to stop request", "error", err)
 		}
-		if close {
-			_ = rc.sess.Close()
+		if result.AfterResponse != nil {
+			result.AfterResponse()
+		}
+		if result.Err == nil && !result.KeepSession {
+			_ = closeSession(rc.sess, ErrSessionStopped)
 		}
 	}
 }
 
 func (rc remoteCallbackHandler) OnRestart(_ *proto.Restart, respond tunnel_client.HandlerRespFunc) {
 	if rc.restartHandler != nil {
-		resp := new(proto.RestartResp)
-		close := true
-		if err := rc.restartHandler(context.TODO(), rc.sess); err != nil {
-			close = false
-			resp.Error = err.Error()
-		}
+		ctx, cancel := rc.commandContext()
+		result := rc.restartHandler(ctx, rc.sess, RestartRequest{})
+		cancel()
+		resp := &proto.RestartResp{Error: errString(result.Err)}
 		if err := respond(resp); err != nil {
 			rc.Warn("error responding to restart request", "error", err)
 		}
-		if close {
-			_ = rc.sess.Close()
+		if result.AfterResponse != nil {
+			result.AfterResponse()
+		}
+		if result.Err == nil && !result.KeepSession {
+			_ = closeSession(rc.sess, ErrSessionStopped)
 		}
 	}
 }
 
-func (rc remoteCallbackHandler) OnUpdate(_ *proto.Update, respond tunnel_client.HandlerRespFunc) {
+func (rc remoteCallbackHandler) OnUpdate(req *proto.Update, respond tunnel_client.HandlerRespFunc) {
 	if rc.updateHandler != nil {
-		resp := new(proto.UpdateResp)
-		if err := rc.updateHandler(context.TODO(), rc.sess); err != nil {
-			resp.Error = err.Error()
-		}
+		ctx, cancel := rc.commandContext()
+		result := rc.updateHandler(ctx, rc.sess, UpdateRequest{
+			Version:            req.Version,
+			PermitMajorVersion: req.PermitMajorVersion,
+		})
+		cancel()
+		resp := &proto.UpdateResp{Error: errString(result.Err)}
 		if err := respond(resp); err != nil {
-			rc.Warn("error responding to restart request", "error", err)
+			rc.Warn("error responding to update request", "error", err)
+		}
+		if result.AfterResponse != nil {
+			result.AfterResponse()
 		}
 	}
 }
@@ -0,0 +1,66 @@
+package ngrok
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SessionExpiryHandler is the callback type for [WithSessionExpiryHandler]
+type SessionExpiryHandler func(ctx context.Context, sess Session, remaining time.Duration)
+
+// WithSessionExpiryHandler configures a function which is called when a
+// [Session] is within lead of the duration limit imposed on it by the ngrok
+// service (see [SessionInfo].SessionDuration). When the limit is reached, the
+// ngrok service ends the session and it reconnects, unless
+// [WithPlannedReconnect] has already moved it onto a new connection.
+//
+// The handler is called at most once per connection, and never for sessions
+// without a duration limit.
+func WithSessionExpiryHandler(lead time.Duration, handler SessionExpiryHandler) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.ExpiryWarningLead = lead
+		cfg.ExpiryHandler = handler
+	}
+}
+
+// WithPlannedReconnect makes a [Session] with a duration limit reconnect on
+// its own lead before the ngrok service would end it. The new connection is
+// established and all tunnels are re-bound before the old connection is
+// given up, so tunnels don't see an abrupt disconnect. Connections already
+// proxied over the old connection are served until the limit is reached.
+//
+// If the planned reconnect fails, the session carries on with its current
+// connection and reconnects as usual once the ngrok service ends it.
+func WithPlannedReconnect(lead time.Duration) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.PlannedReconnectLead = lead
+	}
+}
+
+// Timers for the expiry warning and planned reconnect of the current
+// connection.
+type expiryTimers struct {
+	mu     sync.Mutex
+	timers []*time.Timer
+}
+
+// Schedules fn to run lead before deadline, or immediately if that has
+// already passed.
+func (e *expiryTimers) schedule(deadline time.Time, lead time.Duration, fn func(remaining time.Duration)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.timers = append(e.timers, time.AfterFunc(time.Until(deadline.Add(-lead)), func() {
+		fn(time.Until(deadline))
+	}))
+}
+
+// Stops all pending timers.
+func (e *expiryTimers) stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, t := range e.timers {
+		t.Stop()
+	}
+	e.timers = nil
+}
@@ -0,0 +1,35 @@
+package ngrok
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiryTimers(t *testing.T) {
+	timers := new(expiryTimers)
+	fired := make(chan time.Duration, 2)
+
+	deadline := time.Now().Add(time.Minute)
+	timers.schedule(deadline, time.Minute+time.Second, func(remaining time.Duration) {
+		fired <- remaining
+	})
+	timers.schedule(deadline, time.Second, func(remaining time.Duration) {
+		fired <- remaining
+	})
+
+	select {
+	case remaining := <-fired:
+		require.InDelta(t, time.Minute, remaining, float64(time.Second))
+	case <-time.After(time.Second):
+		t.Fatal("timer already past its lead didn't fire")
+	}
+
+	timers.stop()
+	select {
+	case <-fired:
+		t.Fatal("stopped timer fired")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,135 @@
+package ngrok
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// SessionInfo describes an established [Session]. The values are those of
+// the most recent connection to the ngrok service, and may change when the
+// session reconnects.
+type SessionInfo struct {
+	// The unique ID assigned to this session by the ngrok service.
+	ClientID string
+	// The region the session is connected to.
+	Region string
+	// The version of the tunnel protocol in use.
+	ProtoVersion string
+	// The version of the ngrok server.
+	ServerVersion string
+	// The name of the account the authtoken belongs to.
+	AccountName string
+	// The name of the account's plan.
+	PlanName string
+	// A message the ngrok service asked to be shown to the user, if any.
+	Banner string
+	// How long the ngrok service will allow the session to last before it
+	// must reconnect. Zero if unlimited.
+	SessionDuration time.Duration
+
+	// The address of the ngrok service that was dialed.
+	IngressAddr string
+	// The local and remote addresses of the control connection. When
+	// connecting through a proxy, RemoteAddr is the address of the proxy.
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// The TLS version and cipher suite negotiated for the control connection.
+	// See the constants in [crypto/tls]. Zero if the session runs without
+	// TLS, see [WithSessionTransportTLSSkipped].
+	TLSVersion     uint16
+	TLSCipherSuite uint16
+	// Whether the TLS handshake resumed an earlier TLS session, see
+	// [WithTLSClientSessionCache].
+	TLSResumed bool
+
+	// How long the control connection took to dial, to complete its TLS
+	// handshake, and to authenticate.
+	DialDuration      time.Duration
+	HandshakeDuration time.Duration
+	AuthDuration      time.Duration
+}
+
+// ServerInfo is the information returned by the ngrok service when queried
+// with [Session].ServerInfo.
+type ServerInfo struct {
+	// The region of the ngrok server.
+	Region string
+}
+
+// Details of the control connection to the ngrok service.
+type connectionInfo struct {
+	IngressAddr    string
+	LocalAddr      net.Addr
+	RemoteAddr     net.Addr
+	TLSVersion     uint16
+	TLSCipherSuite uint16
+	TLSResumed     bool
+
+	DialDuration      time.Duration
+	HandshakeDuration time.Duration
+	AuthDuration      time.Duration
+}
+
+func newConnectionInfo(ingressAddr string, conn net.Conn) connectionInfo {
+	info := connectionInfo{
+		IngressAddr: ingressAddr,
+		LocalAddr:   conn.LocalAddr(),
+		RemoteAddr:  conn.RemoteAddr(),
+	}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		info.TLSVersion = state.Version
+		info.TLSCipherSuite = state.CipherSuite
+		info.TLSResumed = state.DidResume
+	}
+	return info
+}
+
+func (s *sessionImpl) Info() SessionInfo {
+	inner := s.inner()
+	return SessionInfo{
+		ClientID:        inner.ClientID,
+		Region:          inner.Region,
+		ProtoVersion:    inner.ProtoVersion,
+		ServerVersion:   inner.ServerVersion,
+		AccountName:     inner.AccountName,
+		PlanName:        inner.PlanName,
+		Banner:          inner.Banner,
+		SessionDuration: time.Duration(inner.SessionDuration) * time.Second,
+
+		IngressAddr:    inner.Connection.IngressAddr,
+		LocalAddr:      inner.Connection.LocalAddr,
+		RemoteAddr:     inner.Connection.RemoteAddr,
+		TLSVersion:     inner.Connection.TLSVersion,
+		TLSCipherSuite: inner.Connection.TLSCipherSuite,
+		TLSResumed:     inner.Connection.TLSResumed,
+
+		DialDuration:      inner.Connection.DialDuration,
+		HandshakeDuration: inner.Connection.HandshakeDuration,
+		AuthDuration:      inner.Connection.AuthDuration,
+	}
+}
+
+func (s *sessionImpl) ServerInfo(ctx context.Context) (ServerInfo, error) {
+	type result struct {
+		info ServerInfo
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := s.inner().SrvInfo()
+		done <- result{ServerInfo{Region: resp.Region}, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ServerInfo{}, ctx.Err()
+	case res := <-done:
+		if res.err != nil {
+			return ServerInfo{}, errServerInfo{res.err}
+		}
+		return res.info, nil
+	}
+}
@@ -0,0 +1,72 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	tunnel_client "golang.ngrok.com/ngrok/internal/tunnel/client"
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+type srvInfoSession struct {
+	tunnel_client.Session
+	srvInfo func() (proto.SrvInfoResp, error)
+}
+
+func (s srvInfoSession) SrvInfo() (proto.SrvInfoResp, error) {
+	return s.srvInfo()
+}
+
+func TestSessionInfo(t *testing.T) {
+	sess := new(sessionImpl)
+	sess.setInner(&sessionInner{
+		ClientID:        "client-id",
+		Region:          "us",
+		SessionDuration: 60,
+		Connection:      connectionInfo{IngressAddr: "tunnel.ngrok.com:443"},
+	})
+
+	info := sess.Info()
+	require.Equal(t, "client-id", info.ClientID)
+	require.Equal(t, "us", info.Region)
+	require.Equal(t, time.Minute, info.SessionDuration)
+	require.Equal(t, "tunnel.ngrok.com:443", info.IngressAddr)
+}
+
+func TestSessionServerInfo(t *testing.T) {
+	sess := new(sessionImpl)
+	sess.setInner(&sessionInner{
+		Session: srvInfoSession{srvInfo: func() (proto.SrvInfoResp, error) {
+			return proto.SrvInfoResp{Region: "eu"}, nil
+		}},
+	})
+	info, err := sess.ServerInfo(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "eu", info.Region)
+
+	sess.setInner(&sessionInner{
+		Session: srvInfoSession{srvInfo: func() (proto.SrvInfoResp, error) {
+			return proto.SrvInfoResp{}, testError
+		}},
+	})
+	_, err = sess.ServerInfo(context.Background())
+	require.ErrorIs(t, err, errServerInfo{})
+	require.ErrorIs(t, err, testError)
+
+	block := make(chan struct{})
+	defer close(block)
+	sess.setInner(&sessionInner{
+		Session: srvInfoSession{srvInfo: func() (proto.SrvInfoResp, error) {
+			<-block
+			return proto.SrvInfoResp{}, nil
+		}},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sess.ServerInfo(ctx)
+	require.True(t, errors.Is(err, context.Canceled))
+}
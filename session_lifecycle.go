@@ -0,0 +1,96 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/multierr"
+)
+
+// ErrSessionClosed is the [Session].Err of a session ended by its Close
+// method.
+var ErrSessionClosed = errors.New("session closed")
+
+// ErrSessionStopped is the [Session].Err of a session ended by a Stop or
+// Restart command from the ngrok dashboard or API.
+var ErrSessionStopped = errors.New("session stopped by the ngrok service")
+
+// ErrAuthFailed matches, using [errors.Is], errors where the ngrok service
+// refused to authenticate the session. If it rejected the authtoken itself,
+// the session doesn't retry and this is its [Session].Err.
+var ErrAuthFailed = errors.New("authentication failed")
+
+func (s *sessionImpl) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *sessionImpl) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+func (s *sessionImpl) Wait(ctx context.Context) error {
+	select {
+	case <-s.done:
+		return s.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Records why the session is ending. Only the first reason is kept.
+func (s *sessionImpl) stopping(reason error) {
+	s.stopOnce.Do(func() {
+		s.stopErr = reason
+	})
+}
+
+// Closes the session for the given reason.
+func (s *sessionImpl) stop(reason error) error {
+	s.stopping(reason)
+	if s.endLifetime != nil {
+		s.endLifetime()
+	}
+	return s.inner().Close()
+}
+
+// Marks the session as ended, once everything running on its behalf has been
+// told to stop. Unless another reason was already recorded, it ended because
+// of lastErr, the last error the session had before it gave up
+// reconnecting. If the session never connected, its error includes
+// connectErrs.
+func (s *sessionImpl) finish(lastErr, connectErrs error) {
+	if lastErr == nil {
+		lastErr = errors.New("session ended")
+	}
+	gaveUp := false
+	s.stopOnce.Do(func() {
+		s.stopErr = lastErr
+		gaveUp = true
+	})
+	s.endLifetime()
+
+	err := s.stopErr
+	if s.readyErr != nil {
+		if gaveUp {
+			// lastErr is one of connectErrs
+			err = connectErrs
+		} else {
+			err = multierr.Append(err, connectErrs)
+		}
+	}
+	s.err = err
+	close(s.done)
+}
+
+// Closes sess for the given reason, if it's one of ours.
+func closeSession(sess Session, reason error) error {
+	if stopper, ok := sess.(interface{ stop(error) error }); ok {
+		return stopper.stop(reason)
+	}
+	return sess.Close()
+}
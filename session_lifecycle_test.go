@@ -0,0 +1,140 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+)
+
+// A Dialer that can never reach the ngrok service.
+type unreachableDialer struct{}
+
+func (unreachableDialer) Dial(network, address string) (net.Conn, error) {
+	return nil, errors.New("unreachable")
+}
+
+func (unreachableDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return nil, errors.New("unreachable")
+}
+
+func waitCtx(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestSessionLifecycleClose(t *testing.T) {
+	failed := make(chan struct{}, 1)
+	sess, err := Connect(context.Background(),
+		WithBackgroundConnect(),
+		WithDialer(unreachableDialer{}),
+		WithDisconnectHandler(func(ctx context.Context, sess Session, err error) {
+			if err != nil {
+				select {
+				case failed <- struct{}{}:
+				default:
+				}
+			}
+		}),
+	)
+	require.NoError(t, err)
+	<-failed
+
+	select {
+	case <-sess.Done():
+		t.Fatal("session ended early")
+	default:
+	}
+	require.NoError(t, sess.Err())
+
+	require.NoError(t, sess.Close())
+	err = sess.Wait(waitCtx(t))
+	require.ErrorIs(t, err, ErrSessionClosed)
+	// it never connected, so the reason why is included
+	require.ErrorIs(t, err, errSessionDial{})
+	require.Equal(t, err, sess.Err())
+	<-sess.Done()
+
+	require.ErrorIs(t, sess.Ready(waitCtx(t)), errSessionDial{})
+}
+
+func TestSessionLifecycleContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sess, err := Connect(ctx, WithBackgroundConnect(), WithDialer(unreachableDialer{}))
+	require.NoError(t, err)
+
+	cancel()
+	require.ErrorIs(t, sess.Wait(waitCtx(t)), context.Canceled)
+
+	// closing afterwards doesn't change why it ended
+	_ = sess.Close()
+	require.ErrorIs(t, sess.Err(), context.Canceled)
+	require.NotErrorIs(t, sess.Err(), ErrSessionClosed)
+}
+
+func TestSessionWaitContext(t *testing.T) {
+	sess, err := Connect(context.Background(), WithBackgroundConnect(), WithDialer(unreachableDialer{}))
+	require.NoError(t, err)
+	defer sess.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, sess.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestSessionStopReason(t *testing.T) {
+	sess := &sessionImpl{
+		ready:       make(chan struct{}),
+		done:        make(chan struct{}),
+		endLifetime: func() {},
+	}
+	sess.setInner(&sessionInner{Session: srvInfoSession{}})
+	sess.markReady(nil)
+
+	sess.stopping(ErrSessionStopped)
+	sess.stopping(ErrSessionClosed)
+	sess.finish(testError, nil)
+	require.Equal(t, ErrSessionStopped, sess.Err())
+}
+
+func TestSessionGaveUpReason(t *testing.T) {
+	rejected := errAuthFailed{true, errors.New("The authtoken you specified is properly formed, but it is invalid.\n\nERR_NGROK_107\n"), false}
+
+	sess := &sessionImpl{
+		ready:       make(chan struct{}),
+		done:        make(chan struct{}),
+		endLifetime: func() {},
+	}
+	sess.markReady(nil)
+	sess.finish(rejected, nil)
+	require.ErrorIs(t, sess.Err(), ErrAuthFailed)
+	require.Equal(t, rejected, sess.Err())
+
+	// never connected: the errors of every attempt are reported
+	sess = &sessionImpl{
+		ready:       make(chan struct{}),
+		done:        make(chan struct{}),
+		endLifetime: func() {},
+	}
+	connectErrs := multierr.Append(testError, rejected)
+	sess.markReady(connectErrs)
+	sess.finish(rejected, connectErrs)
+	require.ErrorIs(t, sess.Err(), ErrAuthFailed)
+	require.ErrorIs(t, sess.Err(), testError)
+}
+
+func TestAuthFailedPermanent(t *testing.T) {
+	require.True(t, errAuthFailed{true, errors.New("invalid authtoken\n\nERR_NGROK_105\n"), false}.Permanent())
+	require.False(t, errAuthFailed{true, errors.New("too many sessions\n\nERR_NGROK_108\n"), false}.Permanent())
+	require.False(t, errAuthFailed{false, errors.New("ERR_NGROK_105"), false}.Permanent())
+	require.False(t, errAuthFailed{true, errors.New("no code"), false}.Permanent())
+	require.False(t, errAuthFailed{true, errors.New("invalid authtoken\n\nERR_NGROK_105\n"), true}.Permanent())
+
+	require.ErrorIs(t, errAuthFailed{true, testError, false}, ErrAuthFailed)
+	require.NotErrorIs(t, errAuthFailed{false, testError, false}, ErrAuthFailed)
+}
@@ -0,0 +1,55 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// SessionTransport is the callback type for [WithSessionTransport]
+type SessionTransport func(ctx context.Context) (net.Conn, error)
+
+// WithSessionTransport configures a function which establishes the
+// connection the session runs over, in place of dialing the ngrok server. It
+// is called for the initial connection and again on every reconnect, so it
+// can run the session over any stream: an SSH channel, a serial bridge, or an
+// in-memory pipe. This option will cause [WithDialer] and [WithProxyURL] to
+// be ignored.
+//
+// The session still performs its TLS handshake with the ngrok server over
+// the returned connection, unless [WithSessionTransportTLSSkipped] is used.
+// [WithServer] sets the name the server's certificate is verified against.
+func WithSessionTransport(transport SessionTransport) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.Transport = transport
+	}
+}
+
+// WithSessionTransportTLSSkipped runs the session directly over the
+// connection returned by the function set with [WithSessionTransport],
+// without the library's own TLS layer. Use it only when the transport is
+// already secured end-to-end with the ngrok service. Certificate options,
+// such as [WithCA] and [WithServerPin], don't apply. It has no effect
+// without [WithSessionTransport].
+func WithSessionTransportTLSSkipped() ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.TransportSkipTLS = true
+	}
+}
+
+// Whether the session runs over its transport without TLS.
+func (cfg *connectConfig) skipsTLS() bool {
+	return cfg.Transport != nil && cfg.TransportSkipTLS
+}
+
+// Establishes the connection the session runs over, before TLS.
+func (cfg *connectConfig) dial(ctx context.Context, dialer Dialer, ingress *ingressCache) (net.Conn, error) {
+	if cfg.Transport == nil {
+		return ingress.dial(ctx, dialer, cfg.ServerAddr)
+	}
+	conn, err := cfg.Transport(ctx)
+	if err == nil && conn == nil {
+		err = errors.New("session transport returned no connection")
+	}
+	return conn, err
+}
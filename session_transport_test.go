@@ -0,0 +1,80 @@
+package ngrok
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a transport over in-memory pipes, which reports the first byte the
+// session writes over each of them and then hangs up.
+func pipeTransport() (SessionTransport, <-chan byte) {
+	first := make(chan byte, 8)
+	return func(ctx context.Context) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			buf := make([]byte, 1)
+			if _, err := io.ReadFull(server, buf); err == nil {
+				first <- buf[0]
+			}
+		}()
+		return client, nil
+	}, first
+}
+
+// The first byte of a TLS handshake record.
+const tlsHandshakeRecord = 0x16
+
+func TestSessionTransport(t *testing.T) {
+	transport, first := pipeTransport()
+	sess, err := Connect(context.Background(),
+		WithBackgroundConnect(),
+		WithDialer(unreachableDialer{}),
+		WithSessionTransport(transport),
+	)
+	require.NoError(t, err)
+	defer sess.Close()
+
+	// used for the first connection and every reconnect
+	for i := 0; i < 2; i++ {
+		select {
+		case b := <-first:
+			require.Equal(t, byte(tlsHandshakeRecord), b)
+		case <-time.After(5 * time.Second):
+			t.Fatal("session didn't use its transport")
+		}
+	}
+}
+
+func TestSessionTransportTLSSkipped(t *testing.T) {
+	transport, first := pipeTransport()
+	sess, err := Connect(context.Background(),
+		WithBackgroundConnect(),
+		WithSessionTransport(transport),
+		WithSessionTransportTLSSkipped(),
+	)
+	require.NoError(t, err)
+	defer sess.Close()
+
+	select {
+	case b := <-first:
+		require.NotEqual(t, byte(tlsHandshakeRecord), b)
+	case <-time.After(5 * time.Second):
+		t.Fatal("session didn't use its transport")
+	}
+}
+
+func TestSessionTransportFailed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := Connect(ctx, WithSessionTransport(func(ctx context.Context) (net.Conn, error) {
+		return nil, testError
+	}))
+	require.ErrorIs(t, err, testError)
+	require.ErrorIs(t, err, errSessionDial{})
+}
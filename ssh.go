@@ -0,0 +1,43 @@
+package ngrok
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"golang.ngrok.com/ngrok/config"
+)
+
+// Runs an SSH server on connections accepted from the listener until it is
+// closed. Configured via [config.WithSSHServer].
+func serveSSH(l net.Listener, cfg *ssh.ServerConfig, handler config.SSHConnHandler, timeout time.Duration) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			// don't let unauthenticated clients hold the connection open
+			_ = conn.SetDeadline(time.Now().Add(timeout))
+			sconn, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+			if err != nil {
+				_ = conn.Close()
+				return
+			}
+			_ = conn.SetDeadline(time.Time{})
+
+			if handler == nil {
+				go ssh.DiscardRequests(reqs)
+				for newChan := range chans {
+					_ = newChan.Reject(ssh.Prohibited, "no handler configured")
+				}
+				_ = sconn.Close()
+				return
+			}
+
+			handler(sconn, chans, reqs)
+		}()
+	}
+}
@@ -0,0 +1,109 @@
+package ngrok
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/inconshreveable/log15/v3"
+)
+
+// CAProvider is the callback type for [WithCAProvider]
+type CAProvider func(ctx context.Context) (*x509.CertPool, error)
+
+// TLSConfigProvider is the callback type for [WithTLSConfigProvider]
+type TLSConfigProvider func(ctx context.Context, cfg *tls.Config) error
+
+// WithCAProvider configures a function which returns the CAs used to validate
+// the TLS certificate of the ngrok service, each time the session connects.
+// It takes precedence over [WithCA]. If it fails, the connection attempt fails
+// and is retried like any other.
+func WithCAProvider(provider CAProvider) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.CAProvider = provider
+	}
+}
+
+// WithCAFile validates the TLS certificate of the ngrok service against the
+// PEM-encoded CAs in path, which is read each time the session connects. Use
+// it with a man-in-the-middle proxy whose CA bundle is rotated in place.
+func WithCAFile(path string) ConnectOption {
+	return WithCAProvider(func(context.Context) (*x509.CertPool, error) {
+		bundle, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in %s", path)
+		}
+		return pool, nil
+	})
+}
+
+// WithTLSConfigProvider configures a function which customizes the TLS
+// connection to the ngrok service each time the session connects. It's
+// applied after [WithTLSConfig]. If it returns an error, the connection attempt
+// fails and is retried like any other.
+func WithTLSConfigProvider(provider TLSConfigProvider) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.TLSConfigProvider = provider
+	}
+}
+
+// The TLS settings for one connection to the ngrok service.
+type dialTLS struct {
+	config *tls.Config
+	// whether the CAs differ from the ngrok defaults
+	customCAs bool
+}
+
+// Builds the TLS settings for the next connection to the ngrok service.
+func (cfg *connectConfig) dialTLS(ctx context.Context, defaultCAs *x509.CertPool, logger log15.Logger) (*dialTLS, error) {
+	pool, customCAs := cfg.CAPool, cfg.CAPool != nil
+	if cfg.CAProvider != nil {
+		var err error
+		if pool, err = cfg.CAProvider(ctx); err != nil {
+			return nil, fmt.Errorf("loading CAs: %w", err)
+		}
+		if pool == nil {
+			return nil, errors.New("loading CAs: no CAs were provided")
+		}
+		customCAs = true
+	}
+	if pool == nil {
+		pool = defaultCAs
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:    pool,
+		ServerName: strings.Split(cfg.ServerAddr, ":")[0],
+		MinVersion: tls.VersionTLS12,
+
+		GetClientCertificate: cfg.GetClientCertificate,
+		ClientSessionCache:   cfg.ClientSessionCache,
+	}
+	if cfg.TLSConfigCustomizer != nil {
+		cfg.TLSConfigCustomizer(tlsConfig)
+	}
+	if cfg.TLSConfigProvider != nil {
+		if err := cfg.TLSConfigProvider(ctx, tlsConfig); err != nil {
+			return nil, fmt.Errorf("configuring TLS: %w", err)
+		}
+	}
+	serverPins{
+		pins:       cfg.ServerPins,
+		reportOnly: cfg.ServerPinReportOnly,
+		logger:     logger,
+	}.install(tlsConfig)
+
+	return &dialTLS{
+		config: tlsConfig,
+		// the customizers may have swapped out the CAs
+		customCAs: customCAs || tlsConfig.RootCAs != pool,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package ngrok
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/inconshreveable/log15/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func testDialTLS(t *testing.T, opts ...ConnectOption) (*dialTLS, *x509.CertPool, error) {
+	cfg := connectConfig{ServerAddr: "connect.example.com:443"}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	defaultCAs := x509.NewCertPool()
+	logger := log15.New()
+	logger.SetHandler(log15.DiscardHandler())
+	settings, err := cfg.dialTLS(context.Background(), defaultCAs, logger)
+	return settings, defaultCAs, err
+}
+
+func TestDialTLSDefaults(t *testing.T) {
+	settings, defaultCAs, err := testDialTLS(t)
+	require.NoError(t, err)
+	require.Same(t, defaultCAs, settings.config.RootCAs)
+	require.Equal(t, "connect.example.com", settings.config.ServerName)
+	require.False(t, settings.customCAs)
+
+	pool := x509.NewCertPool()
+	settings, _, err = testDialTLS(t, WithTLSConfig(func(cfg *tls.Config) {
+		cfg.RootCAs = pool
+	}))
+	require.NoError(t, err)
+	require.Same(t, pool, settings.config.RootCAs)
+	require.True(t, settings.customCAs)
+}
+
+func TestDialTLSCAFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	opt := WithCAFile(path)
+
+	_, _, err := testDialTLS(t, opt)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("not a certificate"), 0600))
+	_, _, err = testDialTLS(t, opt)
+	require.ErrorContains(t, err, "no certificates")
+
+	// the file is re-read on every dial
+	cert := selfSignedCert(t)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600))
+	settings, _, err := testDialTLS(t, opt)
+	require.NoError(t, err)
+	require.True(t, settings.customCAs)
+	_, err = cert.Leaf.Verify(x509.VerifyOptions{Roots: settings.config.RootCAs, DNSName: "localhost"})
+	require.NoError(t, err)
+}
+
+func TestDialTLSProviders(t *testing.T) {
+	_, _, err := testDialTLS(t, WithCAProvider(func(ctx context.Context) (*x509.CertPool, error) {
+		return nil, testError
+	}))
+	require.ErrorIs(t, err, testError)
+
+	_, _, err = testDialTLS(t, WithTLSConfigProvider(func(ctx context.Context, cfg *tls.Config) error {
+		return testError
+	}))
+	require.ErrorIs(t, err, testError)
+
+	// applied after the static customizer
+	settings, _, err := testDialTLS(t,
+		WithTLSConfig(func(cfg *tls.Config) {
+			cfg.ServerName = "static.example.com"
+		}),
+		WithTLSConfigProvider(func(ctx context.Context, cfg *tls.Config) error {
+			require.Equal(t, "static.example.com", cfg.ServerName)
+			cfg.ServerName = "dynamic.example.com"
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "dynamic.example.com", settings.config.ServerName)
+}
@@ -2,6 +2,8 @@ package ngrok
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"net"
 	"time"
 
@@ -31,6 +33,11 @@ type Tunnel interface {
 	// dashboard and the Tunnels API. Use config.WithForwardsTo when
 	// calling Session.Listen to set this value explicitly.
 	ForwardsTo() string
+	// EndpointConfig returns the configuration the ngrok service applied to
+	// the tunnel's endpoint, such as the assigned hostname or port and the
+	// enabled middleware. Type-switch on the result to get the details for
+	// each protocol. Labeled tunnels return nil.
+	EndpointConfig() EndpointConfig
 	// ID returns a tunnel's unique ID.
 	ID() string
 	// Labels returns the labels set by config.WithLabel if this is a
@@ -38,6 +45,9 @@ type Tunnel interface {
 	Labels() map[string]string
 	// Metadata returns the arbitraray metadata string for this tunnel.
 	Metadata() string
+	// SetMetadata replaces the tunnel's metadata. The tunnel is re-bound
+	// with it, without interrupting its connections.
+	SetMetadata(ctx context.Context, metadata string) error
 	// Proto returns the protocol of the tunnel's endpoint.
 	// Labeled tunnels will return the empty string.
 	Proto() string
@@ -47,6 +57,13 @@ type Tunnel interface {
 	// URL returns the tunnel endpoint's URL.
 	// Labeled tunnels will return the empty string.
 	URL() string
+	// Status returns whether the tunnel can currently receive connections.
+	Status() TunnelStatus
+	// StatusChanges returns a channel which receives the tunnel's status
+	// each time it changes, and is closed once the tunnel has failed or been
+	// closed. Changes are dropped if the channel isn't read from promptly;
+	// Status always reports the current status.
+	StatusChanges() <-chan TunnelStatus
 }
 
 // Listen creates a new [Tunnel] after connecting a new [Session]. This is a
@@ -73,6 +90,9 @@ func Listen(ctx context.Context, tunnelConfig config.Tunnel, connectOpts ...Conn
 type tunnelImpl struct {
 	Sess   Session
 	Tunnel tunnel_client.Tunnel
+	// If set, TLS is terminated in the library using this configuration.
+	termination *tls.Config
+	status      *statusWatchers
 }
 
 func (t *tunnelImpl) Accept() (net.Conn, error) {
@@ -80,8 +100,12 @@ func (t *tunnelImpl) Accept() (net.Conn, error) {
 	if err != nil {
 		return nil, errAcceptFailed{Inner: err}
 	}
+	var netConn net.Conn = conn.Conn
+	if t.termination != nil {
+		netConn = tls.Server(netConn, t.termination)
+	}
 	return &connImpl{
-		Conn:  conn.Conn,
+		Conn:  netConn,
 		Proxy: conn,
 	}, nil
 }
@@ -108,6 +132,11 @@ func (t *tunnelImpl) Proto() string {
 	return t.Tunnel.RemoteBindConfig().ConfigProto
 }
 
+func (t *tunnelImpl) EndpointConfig() EndpointConfig {
+	cfg := t.Tunnel.RemoteBindConfig()
+	return newEndpointConfig(cfg.ConfigProto, cfg.URL, cfg.Opts)
+}
+
 func (t *tunnelImpl) ForwardsTo() string {
 	return t.Tunnel.ForwardsTo()
 }
@@ -148,6 +177,12 @@ type Conn interface {
 	// PassthroughTLS returns whether this connection contains an end-to-end tls
 	// connection.
 	PassthroughTLS() bool
+	// PeerCertificates returns the certificate chain presented by the client
+	// when TLS is terminated in the library, completing the handshake first if
+	// necessary. It returns nil for all other connections. An error is
+	// returned if the handshake fails, including when the client's
+	// certificate isn't trusted, or doesn't complete within 10 seconds.
+	PeerCertificates() ([]*x509.Certificate, error)
 }
 
 type EdgeType proto.EdgeType
@@ -159,6 +194,10 @@ const (
 	EdgeTypeHTTPS     EdgeType = 3
 )
 
+// How long a client may take to complete the handshake of a connection whose
+// TLS or SSH is terminated in the library.
+const handshakeTimeout = 10 * time.Second
+
 type connImpl struct {
 	net.Conn
 	Proxy *tunnel_client.ProxyConn
@@ -182,3 +221,16 @@ func (c *connImpl) EdgeType() EdgeType {
 func (c *connImpl) PassthroughTLS() bool {
 	return c.Proxy.Header.PassthroughTLS
 }
+
+func (c *connImpl) PeerCertificates() ([]*x509.Certificate, error) {
+	tlsConn, ok := c.Conn.(*tls.Conn)
+	if !ok {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return tlsConn.ConnectionState().PeerCertificates, nil
+}
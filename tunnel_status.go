@@ -0,0 +1,118 @@
+package ngrok
+
+import (
+	"context"
+	"sync"
+
+	tunnel_client "golang.ngrok.com/ngrok/internal/tunnel/client"
+)
+
+// TunnelStatus is whether a [Tunnel] can currently receive connections.
+type TunnelStatus tunnel_client.TunnelStatus
+
+const (
+	// The tunnel is waiting for its session to connect for the first time.
+	// See [WithBackgroundConnect].
+	TunnelBinding = TunnelStatus(tunnel_client.TunnelBinding)
+	// The tunnel is bound and receiving connections.
+	TunnelOnline = TunnelStatus(tunnel_client.TunnelOnline)
+	// The session lost its connection to the ngrok service, and the tunnel
+	// will be re-bound once it reconnects.
+	TunnelReconnecting = TunnelStatus(tunnel_client.TunnelReconnecting)
+	// The tunnel couldn't be bound, or its session gave up reconnecting.
+	// It won't come back.
+	TunnelFailed = TunnelStatus(tunnel_client.TunnelFailed)
+	// The tunnel was closed.
+	TunnelClosed = TunnelStatus(tunnel_client.TunnelClosed)
+)
+
+func (s TunnelStatus) String() string {
+	return tunnel_client.TunnelStatus(s).String()
+}
+
+// TunnelRebindHandler is the callback type for [WithTunnelRebindHandler]
+type TunnelRebindHandler func(ctx context.Context, tun Tunnel, prevID string)
+
+// WithTunnelRebindHandler configures a function which is called each time a
+// tunnel is re-bound after the [Session] reconnects. The tunnel's ID and URL
+// are those it was re-bound with. Labeled tunnels may be given a new ID, in
+// which case it differs from prevID.
+func WithTunnelRebindHandler(handler TunnelRebindHandler) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.RebindHandler = handler
+	}
+}
+
+// Tracks the status of a Tunnel and the channels to send changes on.
+type statusWatchers struct {
+	mu     sync.Mutex
+	status TunnelStatus
+	chans  []chan TunnelStatus
+}
+
+func newStatusWatchers(status TunnelStatus) *statusWatchers {
+	return &statusWatchers{status: status}
+}
+
+func (w *statusWatchers) get() TunnelStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// Moves to status, telling every watcher. Once the status is terminal, the
+// watchers' channels are closed.
+func (w *statusWatchers) set(status TunnelStatus) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == status || tunnel_client.TunnelStatus(w.status).Terminal() {
+		return
+	}
+	w.status = status
+	for _, ch := range w.chans {
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+	if tunnel_client.TunnelStatus(status).Terminal() {
+		for _, ch := range w.chans {
+			close(ch)
+		}
+		w.chans = nil
+	}
+}
+
+// Returns a channel of status changes.
+func (w *statusWatchers) watch() <-chan TunnelStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ch := make(chan TunnelStatus, 8)
+	if tunnel_client.TunnelStatus(w.status).Terminal() {
+		ch <- w.status
+		close(ch)
+		return ch
+	}
+	w.chans = append(w.chans, ch)
+	return ch
+}
+
+// Keeps the status of t up to date, and calls the rebind handler for owner,
+// the Tunnel returned to the application.
+func (s *sessionImpl) track(t *tunnelImpl, owner Tunnel, watchers *statusWatchers) {
+	t.status = watchers
+	t.Tunnel.ObserveStatus(func(status tunnel_client.TunnelStatus, prevID string) {
+		watchers.set(TunnelStatus(status))
+		if status == tunnel_client.TunnelOnline && prevID != "" && s.rebindHandler != nil {
+			go s.rebindHandler(s.handlerCtx, owner, prevID)
+		}
+	})
+}
+
+func (t *tunnelImpl) Status() TunnelStatus {
+	return t.status.get()
+}
+
+func (t *tunnelImpl) StatusChanges() <-chan TunnelStatus {
+	return t.status.watch()
+}
@@ -0,0 +1,92 @@
+package ngrok
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	tunnel_client "golang.ngrok.com/ngrok/internal/tunnel/client"
+)
+
+func TestStatusWatchers(t *testing.T) {
+	watchers := newStatusWatchers(TunnelOnline)
+	changes := watchers.watch()
+
+	watchers.set(TunnelOnline)
+	watchers.set(TunnelReconnecting)
+	watchers.set(TunnelOnline)
+	watchers.set(TunnelClosed)
+	watchers.set(TunnelOnline)
+
+	var seen []TunnelStatus
+	for status := range changes {
+		seen = append(seen, status)
+	}
+	require.Equal(t, []TunnelStatus{TunnelReconnecting, TunnelOnline, TunnelClosed}, seen)
+	require.Equal(t, TunnelClosed, watchers.get())
+
+	// watching a closed tunnel gets its final status
+	seen = nil
+	for status := range watchers.watch() {
+		seen = append(seen, status)
+	}
+	require.Equal(t, []TunnelStatus{TunnelClosed}, seen)
+}
+
+// An internal tunnel whose status changes are driven by the test.
+type observedTunnel struct {
+	tunnel_client.Tunnel
+	observer tunnel_client.TunnelStatusObserver
+}
+
+func (t *observedTunnel) ObserveStatus(observer tunnel_client.TunnelStatusObserver) {
+	t.observer = observer
+	observer(tunnel_client.TunnelOnline, "")
+}
+
+func TestTunnelRebindHandler(t *testing.T) {
+	type rebind struct {
+		tun    Tunnel
+		prevID string
+	}
+	rebinds := make(chan rebind, 1)
+	sess := &sessionImpl{
+		handlerCtx: context.Background(),
+		rebindHandler: func(ctx context.Context, tun Tunnel, prevID string) {
+			rebinds <- rebind{tun, prevID}
+		},
+	}
+
+	inner := &observedTunnel{}
+	tun := &tunnelImpl{Sess: sess, Tunnel: inner}
+	sess.track(tun, tun, newStatusWatchers(TunnelOnline))
+	require.Equal(t, TunnelOnline, tun.Status())
+
+	changes := tun.StatusChanges()
+	inner.observer(tunnel_client.TunnelReconnecting, "")
+	require.Equal(t, TunnelReconnecting, tun.Status())
+	require.Equal(t, TunnelReconnecting, <-changes)
+
+	inner.observer(tunnel_client.TunnelOnline, "old-id")
+	require.Equal(t, TunnelOnline, <-changes)
+	require.Equal(t, rebind{tun, "old-id"}, <-rebinds)
+}
+
+func TestPoolTunnelStatus(t *testing.T) {
+	first, second := newChanTunnel("first"), newChanTunnel("second")
+	pooled := newPoolTunnel([]Tunnel{first, second})
+	changes := pooled.StatusChanges()
+
+	first.status.set(TunnelReconnecting)
+	require.Equal(t, TunnelOnline, pooled.Status())
+
+	second.status.set(TunnelReconnecting)
+	require.Equal(t, TunnelReconnecting, <-changes)
+	require.Equal(t, TunnelReconnecting, pooled.Status())
+
+	require.NoError(t, pooled.Close())
+	require.Equal(t, TunnelClosed, <-changes)
+	_, ok := <-changes
+	require.False(t, ok)
+}
@@ -0,0 +1,92 @@
+package ngrok
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a self-signed certificate for authenticating TLS clients.
+func selfSignedClientCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// Returns a library-terminated connection with a client presenting
+// clientCert, trusting only trusted.
+func mutualTLSConn(t *testing.T, trusted, clientCert tls.Certificate) *connImpl {
+	serverCert := selfSignedCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(trusted.Leaf)
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(serverCert.Leaf)
+
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+	go func() {
+		tlsClient := tls.Client(client, &tls.Config{
+			ServerName:   "localhost",
+			RootCAs:      rootCAs,
+			Certificates: []tls.Certificate{clientCert},
+		})
+		if tlsClient.Handshake() == nil {
+			// wait for the server to finish with the connection
+			_, _ = tlsClient.Read(make([]byte, 1))
+		}
+		_ = client.Close()
+	}()
+
+	return &connImpl{Conn: tls.Server(server, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})}
+}
+
+func TestPeerCertificates(t *testing.T) {
+	trusted := selfSignedClientCert(t)
+	certs, err := mutualTLSConn(t, trusted, trusted).PeerCertificates()
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	require.Equal(t, trusted.Leaf.Raw, certs[0].Raw)
+
+	certs, err = mutualTLSConn(t, trusted, selfSignedClientCert(t)).PeerCertificates()
+	require.Error(t, err)
+	require.Nil(t, certs)
+}
+
+func TestPeerCertificatesWithoutTLS(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	certs, err := (&connImpl{Conn: server}).PeerCertificates()
+	require.NoError(t, err)
+	require.Nil(t, certs)
+}
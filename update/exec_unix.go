@@ -0,0 +1,26 @@
+//go:build !windows
+
+package update
+
+import (
+	"os"
+	"syscall"
+)
+
+// Atomically replaces the executable at path.
+func replaceExecutable(path string, binary []byte) error {
+	tmp, err := writeTemp(path, binary)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Replaces the running process with the executable at path.
+func restartProcess(path string) error {
+	return syscall.Exec(path, os.Args, os.Environ())
+}
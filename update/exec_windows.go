@@ -0,0 +1,46 @@
+//go:build windows
+
+package update
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Replaces the executable at path. Windows won't replace a running
+// executable, but allows it to be renamed, so the old one is moved aside
+// first and restored if the new one can't be moved into place.
+func replaceExecutable(path string, binary []byte) error {
+	tmp, err := writeTemp(path, binary)
+	if err != nil {
+		return err
+	}
+
+	old := path + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(path, old); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Rename(old, path)
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Starts the executable at path with the same arguments and environment,
+// then exits. Windows has no exec, so the process ID changes.
+func restartProcess(path string) error {
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Env = os.Environ()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,418 @@
+// Package update implements self-updates for applications embedding ngrok,
+// driven by the Update command in the ngrok dashboard or API.
+//
+// Releases are described by a JSON [Manifest] served over HTTP. Each build
+// is signed with an ed25519 key whose public half is compiled into the
+// application, so a compromised manifest or download server can't install
+// arbitrary code. The signature covers the version and platform as well as
+// the executable, see [Sign], so an old or mismatched build can't be passed
+// off as the requested one.
+//
+//	updater := update.New(manifestURL, publicKey, version)
+//	sess, err := ngrok.Connect(ctx,
+//		ngrok.WithUpdateCommandHandler(updater.Handler()),
+//	)
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.ngrok.com/ngrok"
+)
+
+// The largest binary that will be downloaded.
+const maxBinarySize = 512 << 20
+
+// How long an update started by [Updater.Handler] may take.
+const updateTimeout = 10 * time.Minute
+
+// Manifest describes the latest release of an application.
+type Manifest struct {
+	// The version of the release, in semantic versioning format.
+	Version string `json:"version"`
+	// The builds of the release, keyed by "GOOS/GOARCH".
+	Builds map[string]Build `json:"builds"`
+}
+
+// Build is a single platform's executable for a release.
+type Build struct {
+	// The URL to download the executable from. Relative URLs are resolved
+	// against the URL of the manifest.
+	URL string `json:"url"`
+	// The ed25519 signature of the executable, made with [Sign]. Encoded as
+	// base64 in JSON.
+	Signature []byte `json:"signature"`
+}
+
+// Stage is a step of the update process.
+type Stage string
+
+const (
+	StageChecking    Stage = "checking"
+	StageDownloading Stage = "downloading"
+	StageVerifying   Stage = "verifying"
+	StageInstalling  Stage = "installing"
+	StageRestarting  Stage = "restarting"
+)
+
+// ProgressHandler is the callback type for [WithProgressHandler]
+type ProgressHandler func(stage Stage, version string)
+
+// ErrorHandler is the callback type for [WithErrorHandler]
+type ErrorHandler func(err error)
+
+// ErrUpToDate is returned when there is no newer version to update to.
+var ErrUpToDate = errors.New("already running the latest version")
+
+// Option customizes an [Updater].
+type Option func(*Updater)
+
+// WithHTTPClient sets the HTTP client used to fetch the manifest and
+// executables. Defaults to [http.DefaultClient].
+func WithHTTPClient(client *http.Client) Option {
+	return func(u *Updater) {
+		u.client = client
+	}
+}
+
+// WithExecutable sets the path of the executable to replace. Defaults to the
+// path of the running executable.
+func WithExecutable(path string) Option {
+	return func(u *Updater) {
+		u.executable = path
+	}
+}
+
+// WithRestart sets the function used to start the new executable once it has
+// been installed. Defaults to re-executing the current process with the same
+// arguments and environment.
+func WithRestart(restart func(path string) error) Option {
+	return func(u *Updater) {
+		u.restart = restart
+	}
+}
+
+// WithProgressHandler configures a function which is called as the update
+// moves through each [Stage].
+func WithProgressHandler(handler ProgressHandler) Option {
+	return func(u *Updater) {
+		u.progress = handler
+	}
+}
+
+// WithErrorHandler configures a function which is called when an update
+// started by [Updater.Handler] fails after the command was acknowledged.
+// Since the ngrok service has already been answered by then, this is the
+// only place the failure is reported.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(u *Updater) {
+		u.onError = handler
+	}
+}
+
+// Sign returns the signature for the build of version for platform, which
+// is "GOOS/GOARCH". Release tooling uses this to fill in
+// [Build].Signature.
+func Sign(key ed25519.PrivateKey, version, platform string, binary []byte) []byte {
+	return ed25519.Sign(key, signedMessage(version, platform, binary))
+}
+
+// The message that is signed for a build: the version, platform, and hash
+// of the executable. Neither the version nor the platform may contain a
+// newline, so the message is unambiguous.
+func signedMessage(version, platform string, binary []byte) []byte {
+	sum := sha256.Sum256(binary)
+	msg := make([]byte, 0, len(version)+len(platform)+2+len(sum))
+	msg = append(msg, version...)
+	msg = append(msg, '\n')
+	msg = append(msg, platform...)
+	msg = append(msg, '\n')
+	return append(msg, sum[:]...)
+}
+
+// Updater checks for, installs, and restarts into new releases.
+type Updater struct {
+	manifestURL    string
+	publicKey      ed25519.PublicKey
+	currentVersion string
+
+	client     *http.Client
+	executable string
+	restart    func(path string) error
+	progress   ProgressHandler
+	onError    ErrorHandler
+
+	// Held while an update started by Handler is running.
+	updating sync.Mutex
+}
+
+// New creates an [Updater] for an application currently running
+// currentVersion, which fetches its manifest from manifestURL and verifies
+// executables with publicKey.
+func New(manifestURL string, publicKey ed25519.PublicKey, currentVersion string, opts ...Option) *Updater {
+	u := &Updater{
+		manifestURL:    manifestURL,
+		publicKey:      publicKey,
+		currentVersion: currentVersion,
+		client:         http.DefaultClient,
+		restart:        restartProcess,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Handler returns a handler for [ngrok.WithUpdateCommandHandler]. The
+// manifest is checked before the command is answered, so a request that
+// can't be satisfied, such as one for a version that isn't available, fails.
+// Otherwise the command is acknowledged straight away, since downloading an
+// executable can take longer than the ngrok service waits for a response.
+// The update then runs in the background and the process restarts once it's
+// installed. Failures from then on are reported to the [WithErrorHandler]
+// function.
+//
+// Only one update runs at a time. A command that arrives while one is in
+// progress fails.
+func (u *Updater) Handler() ngrok.UpdateCommandHandler {
+	return func(ctx context.Context, _ ngrok.Session, req ngrok.UpdateRequest) ngrok.CommandResult {
+		if !u.updating.TryLock() {
+			return ngrok.CommandResult{Err: errors.New("an update is already in progress")}
+		}
+		rel, err := u.release(ctx, req)
+		if err != nil {
+			u.updating.Unlock()
+			return ngrok.CommandResult{Err: err}
+		}
+		return ngrok.CommandResult{
+			AfterResponse: func() {
+				go func() {
+					defer u.updating.Unlock()
+					if err := u.installAndRestart(rel); err != nil && u.onError != nil {
+						u.onError(err)
+					}
+				}()
+			},
+		}
+	}
+}
+
+func (u *Updater) installAndRestart(rel *release) error {
+	ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+	defer cancel()
+	if err := u.install(ctx, rel); err != nil {
+		return err
+	}
+	return u.Restart(rel.version)
+}
+
+// Update installs the release requested by req in place of the executable,
+// without restarting. It returns the version that was installed.
+func (u *Updater) Update(ctx context.Context, req ngrok.UpdateRequest) (string, error) {
+	rel, err := u.release(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if err := u.install(ctx, rel); err != nil {
+		return "", err
+	}
+	return rel.version, nil
+}
+
+// A build that satisfies an update request.
+type release struct {
+	version  string
+	platform string
+	build    Build
+}
+
+// Fetches the manifest and finds the build that satisfies req.
+func (u *Updater) release(ctx context.Context, req ngrok.UpdateRequest) (*release, error) {
+	u.report(StageChecking, req.Version)
+	manifest, err := u.fetchManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.check(manifest.Version, req); err != nil {
+		return nil, err
+	}
+
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	build, ok := manifest.Builds[platform]
+	if !ok {
+		return nil, fmt.Errorf("version %s has no build for %s", manifest.Version, platform)
+	}
+	return &release{version: manifest.Version, platform: platform, build: build}, nil
+}
+
+// Downloads, verifies, and installs rel in place of the executable.
+func (u *Updater) install(ctx context.Context, rel *release) error {
+	u.report(StageDownloading, rel.version)
+	binary, err := u.download(ctx, rel.build.URL)
+	if err != nil {
+		return err
+	}
+
+	u.report(StageVerifying, rel.version)
+	msg := signedMessage(rel.version, rel.platform, binary)
+	if len(u.publicKey) != ed25519.PublicKeySize || !ed25519.Verify(u.publicKey, msg, rel.build.Signature) {
+		return errors.New("executable signature is invalid")
+	}
+
+	u.report(StageInstalling, rel.version)
+	executable := u.executable
+	if executable == "" {
+		if executable, err = os.Executable(); err != nil {
+			return err
+		}
+	}
+	if err := replaceExecutable(executable, binary); err != nil {
+		return fmt.Errorf("installing version %s: %w", rel.version, err)
+	}
+	return nil
+}
+
+// Restart starts the installed executable, which by default replaces the
+// running process.
+func (u *Updater) Restart(version string) error {
+	u.report(StageRestarting, version)
+	executable := u.executable
+	if executable == "" {
+		var err error
+		if executable, err = os.Executable(); err != nil {
+			return err
+		}
+	}
+	return u.restart(executable)
+}
+
+// Checks whether version satisfies the update request.
+func (u *Updater) check(version string, req ngrok.UpdateRequest) error {
+	latest, err := parseVersion(version)
+	if err != nil {
+		return fmt.Errorf("invalid version in manifest: %w", err)
+	}
+	current, err := parseVersion(u.currentVersion)
+	if err != nil {
+		return fmt.Errorf("invalid current version: %w", err)
+	}
+
+	if req.Version != "" {
+		requested, err := parseVersion(req.Version)
+		if err != nil {
+			return fmt.Errorf("invalid requested version: %w", err)
+		}
+		if requested != latest {
+			return fmt.Errorf("version %s is not available, the latest is %s", req.Version, version)
+		}
+	}
+
+	if !current.less(latest) {
+		return ErrUpToDate
+	}
+	if latest.major != current.major && !req.PermitMajorVersion {
+		return fmt.Errorf("updating to %s is a major version change, which was not permitted", version)
+	}
+	return nil
+}
+
+func (u *Updater) fetchManifest(ctx context.Context) (*Manifest, error) {
+	body, err := u.get(ctx, u.manifestURL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("fetching manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+func (u *Updater) download(ctx context.Context, buildURL string) ([]byte, error) {
+	base, err := url.Parse(u.manifestURL)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(buildURL)
+	if err != nil {
+		return nil, err
+	}
+	binary, err := u.get(ctx, base.ResolveReference(ref).String(), maxBinarySize)
+	if err != nil {
+		return nil, fmt.Errorf("downloading executable: %w", err)
+	}
+	return binary, nil
+}
+
+func (u *Updater) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("response is larger than %d bytes", limit)
+	}
+	return body, nil
+}
+
+func (u *Updater) report(stage Stage, version string) {
+	if u.progress != nil {
+		u.progress(stage, version)
+	}
+}
+
+// Writes binary next to path, then moves it into place so that the
+// executable is never partially written.
+func writeTemp(path string, binary []byte) (string, error) {
+	mode := os.FileMode(0755)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".new-*")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, bytes.NewReader(binary)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,257 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok"
+)
+
+type testRelease struct {
+	server     *httptest.Server
+	publicKey  ed25519.PublicKey
+	binary     []byte
+	executable string
+}
+
+type signFunc func(key ed25519.PrivateKey, version, platform string, binary []byte) []byte
+
+// Serves a manifest for version with a build for the current platform.
+func newTestRelease(t *testing.T, version string, sign signFunc) *testRelease {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	binary := []byte("#!/bin/sh\necho " + version + "\n")
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	manifest := Manifest{
+		Version: version,
+		Builds: map[string]Build{
+			platform: {
+				URL:       "builds/app",
+				Signature: sign(priv, version, platform, binary),
+			},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(manifest)
+	})
+	mux.HandleFunc("/builds/app", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(binary)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	executable := filepath.Join(t.TempDir(), "app")
+	require.NoError(t, os.WriteFile(executable, []byte("old"), 0755))
+
+	return &testRelease{
+		server:     server,
+		publicKey:  pub,
+		binary:     binary,
+		executable: executable,
+	}
+}
+
+func validSignature(priv ed25519.PrivateKey, version, platform string, binary []byte) []byte {
+	return Sign(priv, version, platform, binary)
+}
+
+func (r *testRelease) updater(current string, opts ...Option) *Updater {
+	opts = append([]Option{
+		WithHTTPClient(r.server.Client()),
+		WithExecutable(r.executable),
+	}, opts...)
+	return New(r.server.URL+"/manifest.json", r.publicKey, current, opts...)
+}
+
+func (r *testRelease) installed(t *testing.T) []byte {
+	contents, err := os.ReadFile(r.executable)
+	require.NoError(t, err)
+	return contents
+}
+
+func TestUpdate(t *testing.T) {
+	release := newTestRelease(t, "1.3.0", validSignature)
+
+	var stages []Stage
+	updater := release.updater("1.2.3", WithProgressHandler(func(stage Stage, version string) {
+		stages = append(stages, stage)
+	}))
+
+	version, err := updater.Update(context.Background(), ngrok.UpdateRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "1.3.0", version)
+	require.Equal(t, release.binary, release.installed(t))
+	require.Equal(t, []Stage{StageChecking, StageDownloading, StageVerifying, StageInstalling}, stages)
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(release.executable)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	}
+}
+
+func TestUpdateHandler(t *testing.T) {
+	release := newTestRelease(t, "1.3.0", validSignature)
+
+	restarted := make(chan string, 1)
+	updater := release.updater("1.2.3", WithRestart(func(path string) error {
+		restarted <- path
+		return nil
+	}))
+
+	result := updater.Handler()(context.Background(), nil, ngrok.UpdateRequest{Version: "1.3.0"})
+	require.NoError(t, result.Err)
+	require.Equal(t, []byte("old"), release.installed(t), "must not install before responding")
+	require.NotNil(t, result.AfterResponse)
+
+	result.AfterResponse()
+	select {
+	case path := <-restarted:
+		require.Equal(t, release.executable, path)
+	case <-time.After(5 * time.Second):
+		t.Fatal("update never restarted")
+	}
+	require.Equal(t, release.binary, release.installed(t))
+}
+
+func TestUpdateHandlerRejected(t *testing.T) {
+	release := newTestRelease(t, "1.3.0", validSignature)
+	updater := release.updater("1.2.3", WithRestart(func(path string) error {
+		t.Error("restarted after a rejected update")
+		return nil
+	}))
+
+	handler := updater.Handler()
+	result := handler(context.Background(), nil, ngrok.UpdateRequest{Version: "1.4.0"})
+	require.Error(t, result.Err)
+	require.Nil(t, result.AfterResponse)
+
+	// A rejected command doesn't hold up the next one.
+	result = handler(context.Background(), nil, ngrok.UpdateRequest{Version: "1.3.0"})
+	require.NoError(t, result.Err)
+	require.Equal(t, []byte("old"), release.installed(t))
+}
+
+func TestUpdateHandlerFailure(t *testing.T) {
+	release := newTestRelease(t, "1.3.0", func(priv ed25519.PrivateKey, version, platform string, binary []byte) []byte {
+		return Sign(priv, version, platform, []byte("something else"))
+	})
+
+	failed := make(chan error, 1)
+	updater := release.updater("1.2.3",
+		WithRestart(func(path string) error {
+			t.Error("restarted after a failed update")
+			return nil
+		}),
+		WithErrorHandler(func(err error) {
+			failed <- err
+		}),
+	)
+
+	handler := updater.Handler()
+	result := handler(context.Background(), nil, ngrok.UpdateRequest{Version: "1.3.0"})
+	require.NoError(t, result.Err)
+
+	// A second command is refused until the first one is done.
+	busy := handler(context.Background(), nil, ngrok.UpdateRequest{})
+	require.Error(t, busy.Err)
+
+	result.AfterResponse()
+	select {
+	case err := <-failed:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("failure never reported")
+	}
+	require.Equal(t, []byte("old"), release.installed(t))
+}
+
+func TestUpdateRejected(t *testing.T) {
+	cases := []struct {
+		name    string
+		version string
+		current string
+		req     ngrok.UpdateRequest
+		sign    signFunc
+		err     error
+	}{
+		{
+			name:    "up to date",
+			version: "1.2.3",
+			current: "v1.2.3",
+			sign:    validSignature,
+			err:     ErrUpToDate,
+		},
+		{
+			name:    "major version not permitted",
+			version: "2.0.0",
+			current: "1.2.3",
+			sign:    validSignature,
+		},
+		{
+			name:    "requested version unavailable",
+			version: "1.3.0",
+			current: "1.2.3",
+			req:     ngrok.UpdateRequest{Version: "1.4.0"},
+			sign:    validSignature,
+		},
+		{
+			name:    "bad signature",
+			version: "1.3.0",
+			current: "1.2.3",
+			sign: func(priv ed25519.PrivateKey, version, platform string, binary []byte) []byte {
+				return Sign(priv, version, platform, []byte("something else"))
+			},
+		},
+		{
+			name:    "signed for another version",
+			version: "1.3.0",
+			current: "1.2.3",
+			sign: func(priv ed25519.PrivateKey, version, platform string, binary []byte) []byte {
+				return Sign(priv, "1.2.0", platform, binary)
+			},
+		},
+		{
+			name:    "signed for another platform",
+			version: "1.3.0",
+			current: "1.2.3",
+			sign: func(priv ed25519.PrivateKey, version, platform string, binary []byte) []byte {
+				return Sign(priv, version, "plan9/mips", binary)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			release := newTestRelease(t, tc.version, tc.sign)
+			_, err := release.updater(tc.current).Update(context.Background(), tc.req)
+			require.Error(t, err)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+			}
+			require.Equal(t, []byte("old"), release.installed(t))
+		})
+	}
+}
+
+func TestUpdateMajorVersionPermitted(t *testing.T) {
+	release := newTestRelease(t, "2.0.0", validSignature)
+	version, err := release.updater("1.2.3").Update(context.Background(), ngrok.UpdateRequest{PermitMajorVersion: true})
+	require.NoError(t, err)
+	require.Equal(t, "2.0.0", version)
+	require.Equal(t, release.binary, release.installed(t))
+}
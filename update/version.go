@@ -0,0 +1,44 @@
+package update
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A semantic version. Pre-release and build metadata are ignored.
+type version struct {
+	major, minor, patch int
+}
+
+func parseVersion(s string) (version, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(s, "v"))
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return version{}, fmt.Errorf("malformed version %q", s)
+	}
+
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return version{}, fmt.Errorf("malformed version %q", s)
+		}
+		nums[i] = n
+	}
+	return version{nums[0], nums[1], nums[2]}, nil
+}
+
+func (v version) less(other version) bool {
+	if v.major != other.major {
+		return v.major < other.major
+	}
+	if v.minor != other.minor {
+		return v.minor < other.minor
+	}
+	return v.patch < other.patch
+}
@@ -0,0 +1,93 @@
+package ngrok
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+// ServerBanner is a message the ngrok service asked to be shown to the user
+// when the session was established.
+type ServerBanner struct {
+	Msg string
+}
+
+func (b *ServerBanner) Error() string {
+	return b.Msg
+}
+
+// SessionWarningHandler is the callback type for [WithWarningHandler]
+type SessionWarningHandler func(ctx context.Context, sess Session, warning error)
+
+// WithWarningHandler configures a function which is called with each advisory
+// the ngrok service sends when the [Session] connects or reconnects, such as
+// an [*AgentVersionDeprecated] or a [*ServerBanner]. Each distinct warning is
+// only reported once per [Session], even if it's sent again on reconnect.
+//
+// Use [errors.As] to distinguish the kinds of warnings. The current
+// deprecation warning is also available from [Session].Warnings, and the
+// current banner from [SessionInfo].Banner.
+func WithWarningHandler(handler SessionWarningHandler) ConnectOption {
+	return func(cfg *connectConfig) {
+		cfg.WarningHandler = handler
+	}
+}
+
+// The public warnings for the advisory data sent on auth.
+func sessionWarnings(deprecated *proto.AgentVersionDeprecated, banner string) []error {
+	var warnings []error
+	if deprecated != nil {
+		warnings = append(warnings, &AgentVersionDeprecated{
+			NextMin:  deprecated.NextMin,
+			NextDate: deprecated.NextDate,
+			Msg:      deprecated.Msg,
+		})
+	}
+	if banner != "" {
+		warnings = append(warnings, &ServerBanner{Msg: banner})
+	}
+	return warnings
+}
+
+// Tracks the warnings that have already been reported.
+type seenWarnings struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// Returns the warnings that haven't been seen before, and marks them as seen.
+func (s *seenWarnings) unseen(warnings []error) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen == nil {
+		s.seen = make(map[string]struct{})
+	}
+
+	var fresh []error
+	for _, w := range warnings {
+		key := warningKey(w)
+		if _, ok := s.seen[key]; ok {
+			continue
+		}
+		s.seen[key] = struct{}{}
+		fresh = append(fresh, w)
+	}
+	return fresh
+}
+
+// Identifies a warning by all of its fields, so that a warning that changes
+// in any way is reported again.
+func warningKey(w error) string {
+	switch w := w.(type) {
+	case *AgentVersionDeprecated:
+		return fmt.Sprintf("deprecated %q %s %q", w.NextMin, w.NextDate.Format(time.RFC3339Nano), w.Msg)
+	case *ServerBanner:
+		return fmt.Sprintf("banner %q", w.Msg)
+	default:
+		return fmt.Sprintf("%T %s", w, w.Error())
+	}
+}
@@ -0,0 +1,80 @@
+package ngrok
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.ngrok.com/ngrok/internal/tunnel/proto"
+)
+
+func TestSessionWarnings(t *testing.T) {
+	require.Empty(t, sessionWarnings(nil, ""))
+
+	next := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	warnings := sessionWarnings(&proto.AgentVersionDeprecated{
+		NextMin:  "1.2.3",
+		NextDate: next,
+		Msg:      "please upgrade",
+	}, "hello")
+	require.Len(t, warnings, 2)
+
+	var deprecated *AgentVersionDeprecated
+	require.True(t, errors.As(warnings[0], &deprecated))
+	require.Equal(t, "1.2.3", deprecated.NextMin)
+	require.Equal(t, next, deprecated.NextDate)
+	require.Equal(t, "please upgrade", deprecated.Msg)
+	require.Contains(t, deprecated.Error(), "1.2.3")
+
+	var banner *ServerBanner
+	require.True(t, errors.As(warnings[1], &banner))
+	require.Equal(t, "hello", banner.Msg)
+}
+
+func TestSeenWarnings(t *testing.T) {
+	seen := new(seenWarnings)
+
+	first := sessionWarnings(&proto.AgentVersionDeprecated{NextMin: "1.2.3"}, "hello")
+	require.Len(t, seen.unseen(first), 2)
+
+	again := sessionWarnings(&proto.AgentVersionDeprecated{NextMin: "1.2.3"}, "hello")
+	require.Empty(t, seen.unseen(again))
+
+	changed := sessionWarnings(&proto.AgentVersionDeprecated{NextMin: "1.2.3"}, "goodbye")
+	fresh := seen.unseen(changed)
+	require.Len(t, fresh, 1)
+	require.Equal(t, "goodbye", fresh[0].Error())
+}
+
+func TestSeenWarningsChangedMsg(t *testing.T) {
+	seen := new(seenWarnings)
+
+	first := sessionWarnings(&proto.AgentVersionDeprecated{NextMin: "1.2.3", Msg: "please upgrade"}, "")
+	require.Len(t, seen.unseen(first), 1)
+
+	changed := sessionWarnings(&proto.AgentVersionDeprecated{NextMin: "1.2.3", Msg: "upgrade now"}, "")
+	fresh := seen.unseen(changed)
+	require.Len(t, fresh, 1)
+
+	var deprecated *AgentVersionDeprecated
+	require.True(t, errors.As(fresh[0], &deprecated))
+	require.Equal(t, "upgrade now", deprecated.Msg)
+}
+
+func TestSessionWarningsExcludeBanner(t *testing.T) {
+	sess := new(sessionImpl)
+	sess.setInner(&sessionInner{Banner: "hello"})
+	require.Empty(t, sess.Warnings())
+	require.Equal(t, "hello", sess.Info().Banner)
+
+	sess.setInner(&sessionInner{
+		Banner:             "hello",
+		DeprecationWarning: &proto.AgentVersionDeprecated{NextMin: "1.2.3"},
+	})
+	warnings := sess.Warnings()
+	require.Len(t, warnings, 1)
+	var deprecated *AgentVersionDeprecated
+	require.True(t, errors.As(warnings[0], &deprecated))
+}